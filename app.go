@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1etu/gitdraw/config"
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/font"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/jobs"
+	"github.com/1etu/gitdraw/levels"
+	"github.com/1etu/gitdraw/snapshot"
+)
+
+// App holds the operations shared by the GUI, which binds its exported
+// methods, and the HTTP server.
+type App struct {
+	ctx context.Context
+	cfg config.Config
+
+	// mu guards settings, as the HTTP server runs calls concurrently.
+	// Calls work on a copy from current, and changes replace values
+	// instead of modifying them, so a copy stays valid.
+	mu       sync.Mutex
+	settings settings
+
+	jobs    *jobs.Manager
+	jobsErr error
+}
+
+// settings are what the Set and load calls change.
+type settings struct {
+	push git.PushOptions
+	// layers stacks the layers of drawings that do not list their own
+	layers []draw.Layer
+	cal    draw.Calendar
+	// existing and activity are the contributions designs are planned
+	// on top of, from a saved calendar and from local repositories
+	existing *snapshot.Snapshot
+	activity levels.Counts
+}
+
+// current returns a copy of the settings.
+func (a *App) current() settings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings
+}
+
+// change updates the settings.
+func (a *App) change(fn func(s *settings)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fn(&a.settings)
+}
+
+func NewApp(cfg config.Config) *App {
+	// the gui always pushes a freshly generated history, so it overwrites
+	// the remote branch unless told otherwise
+	a := &App{
+		cfg: cfg,
+		settings: settings{
+			push: git.PushOptions{Force: git.ForceAlways},
+			cal:  draw.GitHub{},
+		},
+	}
+	if cal, err := draw.ParseCalendar(cfg.Calendar); err == nil {
+		a.settings.cal = cal
+	}
+	if first, err := draw.ParseWeekday(cfg.WeekStart); err == nil {
+		a.settings.cal = draw.StartWeek(a.settings.cal, first)
+	}
+	a.openJobs(jobs.DefaultLimit)
+	return a
+}
+
+// openJobs sets up the job manager running up to limit jobs at once.
+// Without a jobs dir the app still works, it just cannot queue.
+func (a *App) openJobs(limit int) {
+	if a.jobs != nil {
+		a.jobs.Close()
+		a.jobs = nil
+	}
+	dir, err := jobs.DefaultDir()
+	if err == nil {
+		a.jobs, err = jobs.NewManager(dir, limit)
+	}
+	a.jobsErr = err
+}
+
+// TextToPoints renders text drawn on year, which fills in {year} and
+// {yy}. A year of 0 is this year.
+func (a *App) TextToPoints(text string, year int) string {
+	now := time.Now()
+	if year == 0 {
+		year = now.Year()
+	}
+	return gridToPoints(draw.Text(strings.ToUpper(expandText(text, year, now))))
+}
+
+// PatternToPoints renders one of draw.Patterns.
+func (a *App) PatternToPoints(name string, seed int) string {
+	grid, ok := draw.Pattern(name, int64(seed))
+	if !ok {
+		return "[]"
+	}
+	return gridToPoints(grid)
+}
+
+// Sprites returns the names of font.Sprites as JSON.
+func (a *App) Sprites() string {
+	data, _ := json.Marshal(font.SpriteNames())
+	return string(data)
+}
+
+// SpritePoints stamps a sprite with its left edge at week.
+func (a *App) SpritePoints(name string, week int) string {
+	s, ok := font.GetSprite(name)
+	if !ok {
+		return "[]"
+	}
+	var grid draw.Grid
+	grid.Stamp(s, week)
+	return gridToPoints(grid)
+}
+
+// TransformPoints applies draw.Transform operations to every layer of a
+// drawing and returns the moved points, or the input unchanged if ops is
+// invalid.
+func (a *App) TransformPoints(pointsJSON string, ops string) string {
+	var d Drawing
+	if err := json.Unmarshal([]byte(pointsJSON), &d); err != nil {
+		return pointsJSON
+	}
+	layers := d.layers(a.current().layers)
+	if err := draw.TransformLayers(layers, ops); err != nil {
+		return pointsJSON
+	}
+	return layersToPoints(layers)
+}
+
+// Defaults returns the loaded config as JSON, for the form to start from.
+func (a *App) Defaults() string {
+	data, _ := json.Marshal(a.cfg)
+	return string(data)
+}
+
+// SetLayer sets the blend mode ("max", "add" or "replace") and level
+// mapping (e.g. "0,1,1,2,2", empty for none) of a named layer, for
+// drawings that do not list their layers. A new layer goes on top.
+func (a *App) SetLayer(name, blend, levels string) string {
+	b, err := draw.ParseBlend(blend)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	l, err := draw.ParseLevels(levels)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	a.change(func(s *settings) {
+		layer := draw.Layer{Name: name, Blend: b, Levels: l}
+		layers := slices.Clone(s.layers)
+		if i := slices.IndexFunc(layers, func(l draw.Layer) bool { return l.Name == name }); i >= 0 {
+			layers[i] = layer
+		} else {
+			layers = append(layers, layer)
+		}
+		s.layers = layers
+	})
+	return "success"
+}
+
+// GenerateRequest describes a drawing to generate, as sent by the GUI and
+// the HTTP API.
+type GenerateRequest struct {
+	Points    Drawing `json:"points"`
+	Year      int     `json:"year"`
+	Intensity int     `json:"intensity"`
+	Fill      bool    `json:"fill"`
+	Remote    string  `json:"remote,omitempty"`
+	// Calendar overrides the app's calendar, see draw.Calendars.
+	Calendar string `json:"calendar,omitempty"`
+	// Overlay previews the graph with the existing contributions, shaded
+	// as the platform will, instead of the design alone.
+	Overlay bool `json:"overlay,omitempty"`
+}
+
+func (a *App) Generate(pointsJSON string, year, intensity int, fillBg bool, remoteURL string) string {
+	req := GenerateRequest{Year: year, Intensity: intensity, Fill: fillBg, Remote: remoteURL}
+	if err := json.Unmarshal([]byte(pointsJSON), &req.Points); err != nil {
+		return "error: invalid points data (corrupted)"
+	}
+	if err := a.generate(req, nil); err != nil {
+		return "error: " + err.Error()
+	}
+	return "success"
+}
+
+// Plan returns the plan summary of a drawing as JSON, the same as the
+// CLI's plan event.
+func (a *App) Plan(pointsJSON string, year, intensity int, fillBg bool) string {
+	req := GenerateRequest{Year: year, Intensity: intensity, Fill: fillBg}
+	if err := json.Unmarshal([]byte(pointsJSON), &req.Points); err != nil {
+		return "{}"
+	}
+	data, _ := json.Marshal(a.plan(req))
+	return string(data)
+}
+
+// PreviewSVG renders points as an SVG contribution graph.
+func (a *App) PreviewSVG(pointsJSON string) string {
+	var d Drawing
+	json.Unmarshal([]byte(pointsJSON), &d)
+	s := a.current()
+	return draw.Flatten(d.layers(s.layers)...).SVG(s.cal)
+}
+
+// ImageToPoints turns a base64 encoded PNG, JPEG or GIF, optionally as a
+// data URL, into points. Dark pixels become high levels unless invert is
+// set.
+func (a *App) ImageToPoints(data string, invert bool) string {
+	if _, b64, ok := strings.Cut(data, ","); ok && strings.HasPrefix(data, "data:") {
+		data = b64
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "[]"
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return "[]"
+	}
+	return gridToPoints(draw.FromImage(img, invert))
+}
+
+// ImportCalendar loads a saved contribution calendar, HTML, SVG or
+// GraphQL JSON, to preview and plan designs on top of. An empty string
+// drops it.
+func (a *App) ImportCalendar(data string) string {
+	var existing *snapshot.Snapshot
+	if data != "" {
+		var err error
+		if existing, err = snapshot.Parse([]byte(data)); err != nil {
+			return "error: " + err.Error()
+		}
+	}
+	a.change(func(s *settings) { s.existing = existing })
+	return "success"
+}
+
+// ExistingPoints returns the imported calendar's levels on the current
+// calendar's graph for year, as points.
+func (a *App) ExistingPoints(year int) string {
+	s := a.current()
+	if s.existing == nil {
+		return "[]"
+	}
+	return gridToPoints(s.existing.Grid(s.cal, year))
+}
+
+// LoadActivity counts the commits of the user in local repositories, a
+// comma separated list of paths, to plan and preview designs on top of.
+// An empty list drops them. It returns the number of commits found.
+func (a *App) LoadActivity(paths string) string {
+	var dirs []string
+	for _, p := range strings.Split(paths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			dirs = append(dirs, p)
+		}
+	}
+	if len(dirs) == 0 {
+		a.change(func(s *settings) { s.activity = nil })
+		return "0"
+	}
+	counts, err := git.Activity(dirs, authors(a.cfg.Email))
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	a.change(func(s *settings) { s.activity = counts })
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return fmt.Sprint(total)
+}
+
+// overlay is the graph once the request's design is drawn over the
+// existing contributions.
+func (s settings) overlay(req GenerateRequest) draw.Grid {
+	_, p := s.cells(req)
+	g, _ := levels.Overlay(s.calendar(req), req.Year, existingCounts(s.existing, s.activity), p)
+	return g
+}
+
+// OverlayPoints returns the levels every day will show once the design is
+// generated on top of the existing contributions, as points.
+func (a *App) OverlayPoints(pointsJSON string, year, intensity int, fillBg bool) string {
+	req := GenerateRequest{Year: year, Intensity: intensity, Fill: fillBg}
+	if err := json.Unmarshal([]byte(pointsJSON), &req.Points); err != nil {
+		return "[]"
+	}
+	return gridToPoints(a.current().overlay(req))
+}
+
+// calendar is the request's calendar, or the app's if it names none.
+// Requests are checked by the server, so an unknown name also falls back.
+func (s settings) calendar(req GenerateRequest) draw.Calendar {
+	if req.Calendar == "" {
+		return s.cal
+	}
+	if cal, err := draw.ParseCalendar(req.Calendar); err == nil {
+		return cal
+	}
+	return s.cal
+}
+
+func (s settings) cells(req GenerateRequest) (draw.Grid, levels.Plan) {
+	grid := draw.Flatten(req.Points.layers(s.layers)...)
+	opts := levels.Options{Calendar: s.calendar(req), Fill: req.Fill, Top: req.Intensity}
+	opts.Existing = existingCounts(s.existing, s.activity)
+	return grid, levels.PlanGrid(grid, req.Year, opts)
+}
+
+func (a *App) plan(req GenerateRequest) planEvent {
+	_, p := a.current().cells(req)
+	return newPlan(p, req.Year, git.Estimate(p.Foreground, p.Background, git.Style{}))
+}
+
+// repo builds the repository settings and cells for a request, with the
+// author from the config or git's user settings.
+func (a *App) repo(req GenerateRequest, s settings) (*git.Repo, []draw.Cell, error) {
+	name, email := getGitUser()
+	if a.cfg.Author != "" {
+		name = a.cfg.Author
+	}
+	if a.cfg.Email != "" {
+		email = a.cfg.Email
+	}
+	if name == "" {
+		name = "gitdraw"
+	}
+	if email == "" {
+		return nil, nil, fmt.Errorf("git user.email not configured (try 'git config --global user.email')")
+	}
+
+	grid, p := s.cells(req)
+
+	bgIntensity := 0
+	if req.Fill {
+		bgIntensity = 1
+	}
+
+	repo := &git.Repo{Author: name, Email: email, Location: a.cfg.Location()}
+	repo.Design = &git.Design{Year: req.Year, Intensity: req.Intensity, Background: bgIntensity, Grid: grid}
+	if cal := s.calendar(req); !draw.Default(cal) {
+		repo.Design.Calendar = cal.Name()
+	}
+	return repo, append(p.Background, p.Foreground...), nil
+}
+
+func normalizeRemote(remote string) string {
+	if remote != "" && !strings.Contains(remote, "://") && !strings.HasPrefix(remote, "git@") {
+		return "https://" + remote
+	}
+	return remote
+}
+
+func (a *App) generate(req GenerateRequest, progress func(int, int)) error {
+	s := a.current()
+	repo, cells, err := a.repo(req, s)
+	if err != nil {
+		return err
+	}
+
+	// the work dir is named after the plan, so generating the same design
+	// again after a failure resumes it or just retries the push
+	plan := repo.PlanHash(cells)
+	repo.Path = filepath.Join(os.TempDir(), "gitdraw-"+plan[:16])
+	if cp, _ := repo.ReadCheckpoint(); cp == nil || cp.Plan != plan {
+		os.RemoveAll(repo.Path)
+		if _, err := git.Init(repo.Path); err != nil {
+			return fmt.Errorf("git init failed (probs git not installed)")
+		}
+	}
+
+	if err := repo.FastImportCells(cells, progress); err != nil {
+		return fmt.Errorf("commit generation failed - try again to resume: %w", err)
+	}
+
+	if remote := normalizeRemote(req.Remote); remote != "" {
+		if err := repo.AddRemote(s.push.Remote, remote); err != nil {
+			return fmt.Errorf("failed to add remote")
+		}
+
+		if err := repo.Push(s.push); err != nil {
+			return fmt.Errorf("push failed - %v", err)
+		}
+	}
+
+	os.RemoveAll(repo.Path)
+	return nil
+}
+
+// submit queues a request with the job manager.
+func (a *App) submit(req GenerateRequest) (jobs.Job, error) {
+	if a.jobs == nil {
+		return jobs.Job{}, fmt.Errorf("job manager unavailable: %v", a.jobsErr)
+	}
+	s := a.current()
+	repo, cells, err := a.repo(req, s)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	remote := normalizeRemote(req.Remote)
+	name := fmt.Sprintf("%d, intensity %d", req.Year, req.Intensity)
+	if remote != "" {
+		name += " to " + remote
+	}
+	return a.jobs.Submit(name, jobs.Spec{Repo: *repo, Cells: cells, Remote: remote, Push: s.push}), nil
+}
+
+// QueueGenerate is Generate as a background job. It returns the job as
+// JSON, or an error string.
+func (a *App) QueueGenerate(pointsJSON string, year, intensity int, fillBg bool, remoteURL string) string {
+	req := GenerateRequest{Year: year, Intensity: intensity, Fill: fillBg, Remote: remoteURL}
+	if err := json.Unmarshal([]byte(pointsJSON), &req.Points); err != nil {
+		return "error: invalid points data (corrupted)"
+	}
+	j, err := a.submit(req)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	data, _ := json.Marshal(j)
+	return string(data)
+}
+
+// Jobs returns the job history as JSON, newest first.
+func (a *App) Jobs() string {
+	if a.jobs == nil {
+		return "[]"
+	}
+	data, _ := json.Marshal(a.jobs.List())
+	return string(data)
+}
+
+func (a *App) CancelJob(id string) string {
+	if a.jobs == nil {
+		return "error: job manager unavailable"
+	}
+	if err := a.jobs.Cancel(id); err != nil {
+		return "error: " + err.Error()
+	}
+	return "success"
+}
+
+// SetCalendar picks the platform calendar, one of draw.Calendars, that
+// designs are laid out, previewed and generated for. A first weekday can
+// follow the platform, as in github-monday.
+func (a *App) SetCalendar(name string) string {
+	cal, err := draw.ParseCalendar(name)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	a.change(func(s *settings) { s.cal = cal })
+	return "success"
+}
+
+// CalendarLayout describes the current calendar's graph for year as JSON:
+// the date of the top left cell, the window of days it shows, the
+// weekday of each row and the colours of each level.
+func (a *App) CalendarLayout(year int) string {
+	cal := a.current().cal
+	from, to := cal.Window(year)
+	var weekdays [draw.Rows]string
+	for row, day := range draw.Weekdays(cal) {
+		weekdays[row] = day.String()[:3]
+	}
+	data, _ := json.Marshal(struct {
+		Name     string                    `json:"name"`
+		Start    string                    `json:"start"`
+		From     string                    `json:"from"`
+		To       string                    `json:"to"`
+		Rolling  bool                      `json:"rolling"`
+		Weekdays [draw.Rows]string         `json:"weekdays"`
+		Colors   [draw.MaxLevel + 1]string `json:"colors"`
+	}{
+		Name:     cal.Name(),
+		Start:    draw.Start(cal, year).Format("2006-01-02"),
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Rolling:  draw.Rolling(cal),
+		Weekdays: weekdays,
+		Colors:   cal.Colors(),
+	})
+	return string(data)
+}
+
+// SetPushOptions changes the remote name, remote branch and force mode
+// ("none", "lease" or "force") used by Generate.
+func (a *App) SetPushOptions(remote, branch, force string) string {
+	mode, err := git.ParseForce(force)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	a.change(func(s *settings) { s.push = git.PushOptions{Remote: remote, Branch: branch, Force: mode} })
+	return "success"
+}
+
+func getGitUser() (string, string) {
+	name, _ := exec.Command("git", "config", "user.name").Output()
+	email, _ := exec.Command("git", "config", "user.email").Output()
+	return strings.TrimSpace(string(name)), strings.TrimSpace(string(email))
+}
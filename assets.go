@@ -0,0 +1,8 @@
+package main
+
+import "embed"
+
+// assets is the web frontend, served by the GUI and by gitdraw serve.
+//
+//go:embed gui
+var assets embed.FS
@@ -11,9 +11,11 @@ import (
 
 	"github.com/1etu/gitdraw/draw"
 	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/levels"
+	"github.com/1etu/gitdraw/shard"
 )
 
-const (
+var (
 	reset  = "\033[0m"
 	dim    = "\033[2m"
 	bold   = "\033[1m"
@@ -29,9 +31,9 @@ func main() {
 		arg := os.Args[1]
 		switch arg {
 		case "--gui", "-g":
-			fmt.Println("\n  This build does not include GUI support.")
-			fmt.Println("  Build with: wails build -tags gui")
-			fmt.Println()
+			fmt.Fprintln(console, "\n  This build does not include GUI support.")
+			fmt.Fprintln(console, "  Build with: wails build -tags gui")
+			fmt.Fprintln(console)
 			os.Exit(1)
 		case "--help", "-h":
 			printHelp()
@@ -39,19 +41,52 @@ func main() {
 		case "--version", "-v":
 			printVersion()
 			return
+		case "erase":
+			runErase(os.Args[2:])
+			return
+		case "edit":
+			runEdit(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "sprites":
+			runSprites(os.Args[2:])
+			return
+		case "marquee":
+			runMarquee(os.Args[2:])
+			return
+		case "schedule":
+			runSchedule(os.Args[2:])
+			return
 		}
 	}
 
-	runCLI()
+	runCLI(os.Args[1:])
 }
 
 func printHelp() {
-	fmt.Print(`
+	fmt.Fprint(console, `
   gitdraw — contribution graph art
 
   Usage:
-    gitdraw          Run interactive CLI
-    gitdraw --help   Show this help
+    gitdraw [options]      Run interactive CLI
+    gitdraw erase <repo>   Remove gitdraw commits from a repository
+    gitdraw edit [file]    Paint a design in the terminal, then generate it
+                           (takes the same options)
+    gitdraw serve [--addr host:port]
+                           HTTP/JSON API and the editor in a browser
+                           (default 127.0.0.1:8080)
+    gitdraw sprites [name...]
+                           List and preview the sprites for :name: in text
+    gitdraw marquee --text <msg> --start <date> <repo>
+                           Scroll a message one column a week; run daily to
+                           add what is due to an existing repository
+    gitdraw schedule add <name> [design.json] [options]
+                           Draw a design in real time: each day's commits
+                           are made on the day by tick or run
+    gitdraw schedule list | tick [name...] | run [--every 1h] | remove <name>
+    gitdraw --help         Show this help
 
   Build with GUI:
     wails build -tags gui
@@ -59,53 +94,195 @@ func printHelp() {
   Flags:
     -h, --help     Show help
     -v, --version  Show version
+
+  Options:
+    --message <tmpl>   Commit message template (default "draw {index}/{total}")
+    --content <mode>   overwrite, append, rotate or daily
+    --entry <tmpl>     Line written by append/rotate/daily
+    --files <a,b,...>  File path templates for the content strategy
+    --remote-name <n>  Remote to add and push to (default "origin")
+    --branch <name>    Remote branch to push to (default "main")
+    --push-mode <m>    none, lease (--force-with-lease) or force
+    --pattern <name>   Start from a pattern (gradient, checker, stripes,
+                       noise, activity); text is drawn on top
+    --pattern-levels <m>
+                       Remap pattern levels 0-4, e.g. "0,1,1,2,2"
+    --blend <mode>     How text combines with the pattern: replace
+                       (default), max or add
+    --seed <n>         Seed for the noise and activity patterns
+    --layer <src>      Stack layers instead, bottom first, repeatable:
+                       text, a pattern or a points file, then optionally
+                       :max, :add or :replace and :levels,
+                       e.g. --layer noise:max:0,1,1,1,2 --layer text
+    --output <mode>    text (default) or json: plan, progress and result
+                       as newline delimited JSON on stdout
+    --config <file>    Config file (default ./gitdraw.yaml, then
+                       $XDG_CONFIG_HOME/gitdraw/config.yaml)
+    --text <text>      Default answer for "Text to draw"
+    --year <n>         Default target year
+    --intensity <n>    Default text intensity
+    --fill             Fill the background without asking
+    --author <a>       Commit author, "Name" or "Name <email>"
+    --remote <url>     Default GitHub URL
+    --timezone <tz>    Date commits in this timezone, e.g. Europe/Berlin
+    --dir <dir>        Default output directory
+    --calendar <name>  Lay out for github, gitlab, gitlab-monday or gitea
+    --week-start <day> Weekday of the top row, e.g. monday
+    --existing <file>  Saved contribution calendar (HTML, SVG or GraphQL
+                       JSON) to preview and plan the design on top of
+    --activity <dirs>  Comma separated local repos whose commits are
+                       already on the graph, counted with git log
+    --preview-svg <f>  Write an SVG of how the graph will look
+    --transform <ops>  Reposition the design, e.g. "scroll:10,mirror-v".
+                       Ops: shift:W[:D], scroll:W[:D], mirror-h, mirror-v,
+                       rotate[:DEG], invert, scale:F, crop:W0:D0:W1:D1,
+                       copy:W0:D0:W1:D1:W:D
+    --min-commits      Use the fewest commits that keep the shading
+                       (asked anyway when a plan looks too big)
+    --shard <by>       Split the commits across repositories, each
+                       generated and pushed on its own: year, layer
+                       or round-robin
+    --shards <n>       Repositories for round-robin (default 2)
+    --yes              Answer every question with its default, so a run
+                       is driven by flags alone; --fill and
+                       --min-commits are not assumed
+    --force            Allow deleting an existing output directory that
+                       gitdraw did not create
+
+  Erase options:
+    --dry-run          List the gitdraw commits without changing anything
+    --branch <name>    Branch to clean (default: current)
+    --yes              Skip confirmation
+
+  Marquee options:
+    --start <date>     Week of the first column, yyyy-mm-dd
+    --loop             Start over once the message has scrolled in
+    --today <date>     Commit up to this day instead of today
+    --branch <name>    Branch to add to (default: current)
+    --remote-name <n>  Push there afterwards
+    --dry-run          List what is due without committing
+    also --text, --fill, --intensity, --author, --timezone, --calendar,
+    --week-start, --message, --content, --push-mode and --config
+
+  Schedule add options:
+    --missed <policy>  What to do about a day nothing ran: skip
+                       (default) or backfill
+    also the options of a plain run
+
+  Template fields:
+    {date} {date:Jan 2} {index} {total} {week} {day} {weekday} {level} {message}
+
+  Config file (flags override it):
+    year, intensity, fill, author, email, remote, font, timezone, output, text
+
+  Text fields:
+    {year} {yy} {user} {date} {date:Jan}
+    :heart: :star: :gopher: ... draw a sprite (see gitdraw sprites)
 `)
 }
 
 func printVersion() {
-	fmt.Println("gitdraw v1.0.0")
+	fmt.Fprintln(console, "gitdraw v"+version)
 }
 
-func runCLI() {
+func runCLI(args []string) {
+	opts, err := parseRunFlags(args)
+	if err == nil {
+		err = setupOutput(opts.output)
+		unattended = opts.yes
+	}
+	if err != nil {
+		exit(err.Error())
+	}
+	defer finish()
+
 	clearScreen()
 	printHeader()
 
-	text := ask("Text to draw")
-	if text == "" {
-		exit("text cannot be empty")
+	text := opts.cfg.Text
+	if opts.drawsText() {
+		if text == "" {
+			text = ask("Text to draw")
+		} else {
+			text = askWithDefault("Text to draw", text)
+		}
+		if text == "" && opts.pattern == "" && len(opts.layers) < 2 {
+			exit("text cannot be empty")
+		}
 	}
 
-	grid := draw.Text(strings.ToUpper(text))
+	grid, layers, err := opts.design(text)
+	if err != nil {
+		exit(err.Error())
+	}
 
-	fmt.Println()
-	printPreview(grid)
+	fmt.Fprintln(console)
+	printPreview(opts.calendar, grid)
 
 	if !confirm("Continue with this design") {
-		fmt.Println()
-		fmt.Println(dim + "Cancelled." + reset)
+		fmt.Fprintln(console)
+		fmt.Fprintln(console, dim+"Cancelled."+reset)
+		result.Error = "cancelled"
 		return
 	}
 
-	year := askWithDefault("Target year", fmt.Sprintf("%d", time.Now().Year()))
+	generate(opts, grid, layers)
+}
+
+// generate asks for the remaining settings and writes, and optionally
+// pushes, the repository for grid. layers, if known, are what grid was
+// composited from.
+func generate(opts runOptions, grid draw.Grid, layers []draw.Layer) {
+	year := askWithDefault("Target year", fmt.Sprintf("%d", opts.year()))
 	var yearInt int
 	fmt.Sscanf(year, "%d", &yearInt)
 	if yearInt < 2008 || yearInt > 2099 {
 		yearInt = time.Now().Year()
 	}
+	if draw.Rolling(opts.calendar) && yearInt != time.Now().Year() {
+		warn(fmt.Sprintf("%s only shows the last year, a design for %d will not be visible", opts.calendar.Name(), yearInt))
+	}
+	if from, to := opts.calendar.Window(yearInt); opts.shardBy == shard.ByYear && from.Year() == to.Year() {
+		exit(fmt.Sprintf("--shard year has nothing to split: the %d graph shows a single year", yearInt))
+	}
 
-	dates := grid.Dates(yearInt)
+	existing := opts.existingCounts()
+	if existing != nil {
+		total, days := 0, 0
+		for _, c := range (draw.Grid{}).BackgroundCells(opts.calendar, yearInt, 0) {
+			if n := existing.Get(c.Date); n > 0 {
+				total, days = total+n, days+1
+			}
+		}
+		if days == 0 {
+			warn(fmt.Sprintf("no existing contributions on the %d graph", yearInt))
+		} else {
+			info("existing contributions", fmt.Sprintf("%d on %d days", total, days))
+		}
+		if opts.existing != nil {
+			if n := opts.existing.Uncounted(); n > 0 {
+				warn(fmt.Sprintf("%d days of the saved calendar only have levels, so they count as their level", n))
+			}
+		}
+	}
 
-	fillMode := confirm("Fill background? (creates contrast)")
+	var fillMode bool
+	if opts.cfg.Fill != nil {
+		fillMode = *opts.cfg.Fill
+	} else {
+		fillMode = offer("Fill background? (creates contrast)")
+	}
 
-	var bgDates []time.Time
 	var bgIntensity int
-
 	if fillMode {
-		bgDates = grid.BackgroundDates(yearInt)
 		bgIntensity = 1
 	}
 
-	intensity := askWithDefault("Text intensity", "15")
+	defaultIntensity := opts.cfg.Intensity
+	if defaultIntensity == 0 {
+		defaultIntensity = 15
+	}
+	intensity := askWithDefault("Text intensity", fmt.Sprintf("%d", defaultIntensity))
 	var intensityInt int
 	fmt.Sscanf(intensity, "%d", &intensityInt)
 	if intensityInt < 1 {
@@ -115,84 +292,189 @@ func runCLI() {
 		intensityInt = 50
 	}
 
-	totalCommits := len(dates)*intensityInt + len(bgDates)*bgIntensity
+	// the darkest cells get the full intensity and the others just enough
+	// to land in their level under the platform's shading
+	plan := levels.PlanGrid(grid, yearInt, levels.Options{Calendar: opts.calendar, Fill: fillMode, Top: intensityInt, Existing: existing})
+	budget := git.Estimate(plan.Foreground, plan.Background, opts.style)
 
-	fmt.Println()
-	info("text pixels", fmt.Sprintf("%d", len(dates)))
+	fmt.Fprintln(console)
+	info("pixels", fmt.Sprintf("%d", len(plan.Foreground)))
 	if fillMode {
-		info("background pixels", fmt.Sprintf("%d", len(bgDates)))
+		info("background pixels", fmt.Sprintf("%d", len(plan.Background)))
 	}
-	info("total commits", fmt.Sprintf("%d", totalCommits))
+	info("total commits", fmt.Sprintf("%d", budget.Commits))
 	info("target year", fmt.Sprintf("%d", yearInt))
+	info("estimated size", fmt.Sprintf("%s repository, %s push",
+		git.FormatBytes(budget.RepoBytes), git.FormatBytes(budget.PushBytes)))
+	if n := len(plan.Unsatisfiable); n > 0 {
+		fmt.Fprintln(console)
+		warn(fmt.Sprintf("%d cells cannot show at their level on %s", n, opts.calendar.Name()))
+		if _, ok := levels.For(opts.calendar).(levels.ByQuartile); ok && !fillMode {
+			warn("filling the background gives the design the lower quartiles to stand out from")
+		}
+	}
 
-	repoPath := askWithDefault("Output directory", "gitdraw-repo")
+	minimum := opts.minimum
+	if budget.Heavy() && !minimum {
+		fmt.Fprintln(console)
+		for _, w := range budget.Warnings {
+			warn(w)
+		}
+		if budget.Background > 0 {
+			warn(fmt.Sprintf("the background adds %d commits, skip \"Fill background\" to drop them", budget.Background))
+		}
+		if opts.pattern != "" {
+			warn("the pattern covers most of the graph, --pattern-levels 0,1,1,1,1 keeps it faint and cheap")
+		}
+		if opts.shardBy == "" {
+			warn("--shard splits the commits across several repositories, each pushed on its own")
+		}
+		fewest := levels.PlanGrid(grid, yearInt, levels.Options{Calendar: opts.calendar, Fill: fillMode, Existing: existing})
+		total := draw.TotalCommits(fewest.Foreground) + draw.TotalCommits(fewest.Background)
+		minimum = offer(fmt.Sprintf("Use the fewest commits that keep the shading (%d)", total))
+	}
+	if minimum {
+		plan = levels.PlanGrid(grid, yearInt, levels.Options{Calendar: opts.calendar, Fill: fillMode, Existing: existing})
+		budget = git.Estimate(plan.Foreground, plan.Background, opts.style)
+		info("total commits", fmt.Sprintf("%d", budget.Commits))
+	}
 
-	if dirExists(repoPath) {
-		fmt.Println()
-		warn("directory already exists: " + repoPath)
-		if !confirm("Overwrite") {
-			fmt.Println(dim + "Cancelled." + reset)
-			return
+	// what the graph will look like, shaded with the existing
+	// contributions the way the platform will
+	overlay, _ := levels.Overlay(opts.calendar, yearInt, existing, plan)
+	if existing != nil {
+		fmt.Fprintln(console)
+		printGraph("With your existing contributions", opts.calendar, overlay)
+	}
+	if opts.previewSVG != "" {
+		if err := os.WriteFile(opts.previewSVG, []byte(overlay.SVG(opts.calendar)), 0644); err != nil {
+			warn(err.Error())
+		} else {
+			info("preview", opts.previewSVG)
 		}
-		os.RemoveAll(repoPath)
 	}
 
-	fmt.Println()
-	spin("Initializing repository", func() error {
-		_, err := git.Init(repoPath)
-		return err
-	})
+	cells := append(plan.Background, plan.Foreground...)
+	totalCommits := budget.Commits
+	emitPlan(plan, yearInt, budget)
+	if totalCommits == 0 {
+		exit(fmt.Sprintf("nothing to generate, none of the design's days in %d have come yet", yearInt))
+	}
 
-	repo := &git.Repo{Path: repoPath}
+	defaultOutput := opts.cfg.Output
+	if defaultOutput == "" {
+		defaultOutput = "gitdraw-repo"
+	}
+	repoPath := askWithDefault("Output directory", defaultOutput)
 
-	fmt.Println()
-	if !confirm(fmt.Sprintf("Generate %d commits", totalCommits)) {
-		fmt.Println(dim + "Repository created but empty." + reset)
+	design := &git.Design{Year: yearInt, Intensity: intensityInt, Background: bgIntensity, Grid: grid}
+	if !draw.Default(opts.calendar) {
+		design.Calendar = opts.calendar.Name()
+	}
+	if opts.shardBy != "" {
+		generateShards(opts, plan, layers, repoPath, design)
 		return
 	}
 
-	fmt.Println()
-	width := 40
-	var importErr error
-	if fillMode {
-		importErr = repo.FastImportLayers(bgDates, bgIntensity, dates, intensityInt, func(done, total int) {
-			pct := float64(done) / float64(total)
-			filled := int(pct * float64(width))
-			bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
-			fmt.Printf("\r  %s%s%s %s%3d%%%s", cyan, bar, reset, dim, int(pct*100), reset)
-		})
-	} else {
-		importErr = repo.FastImport(dates, intensityInt, func(done, total int) {
-			pct := float64(done) / float64(total)
-			filled := int(pct * float64(width))
-			bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
-			fmt.Printf("\r  %s%s%s %s%3d%%%s", cyan, bar, reset, dim, int(pct*100), reset)
-		})
+	repo := &git.Repo{
+		Path:     repoPath,
+		Author:   opts.cfg.Author,
+		Email:    opts.cfg.Email,
+		Style:    opts.style,
+		Location: opts.cfg.Location(),
+		Design:   design,
 	}
-	fmt.Println()
+	result.Repo = repoPath
 
-	if importErr != nil {
-		fmt.Printf("\n  %s!%s %s\n", bold+yellow, reset, "commit generation failed")
+	cp, ok := prepareRepo(repo, cells, opts.force)
+	if !ok {
+		result.Error = "cancelled"
 		return
 	}
 
-	fmt.Println()
+	if cp == nil || !cp.Complete() {
+		fmt.Fprintln(console)
+		if !confirm(fmt.Sprintf("Generate %d commits", totalCommits)) {
+			fmt.Fprintln(console, dim+"Repository created but empty."+reset)
+			result.Error = "cancelled"
+			return
+		}
+		if !importCells(repo, cells) {
+			return
+		}
+	}
+
+	result.Commits = totalCommits
+	fmt.Fprintln(console)
 	success("Repository ready")
-	fmt.Println()
+	fmt.Fprintln(console)
 
 	if confirm("Configure GitHub remote") {
-		configureRemote(repo, repoPath)
+		configureRemote(repo, repoPath, opts.cfg.Remote, opts.push)
 	} else {
 		printNextSteps(repoPath)
 	}
 }
 
-func configureRemote(repo *git.Repo, repoPath string) {
-	fmt.Println()
-	fmt.Println(dim + "  Create an empty repo at github.com/new (no README)" + reset)
-	fmt.Println()
+// prepareRepo picks up an earlier run of the same cells in repo.Path, or
+// makes a new repository there. It reports false if the user cancelled.
+func prepareRepo(repo *git.Repo, cells []draw.Cell, force bool) (*git.Checkpoint, bool) {
+	cp := resumable(repo, cells)
+	if cp != nil {
+		return cp, true
+	}
+	if !prepareOutputDir(repo.Path, force) {
+		return nil, false
+	}
+
+	fmt.Fprintln(console)
+	spin("Initializing repository", func() error {
+		_, err := git.Init(repo.Path)
+		return err
+	})
+	return nil, true
+}
+
+// importCells generates the commits with a progress bar. It reports
+// whether they were all made.
+func importCells(repo *git.Repo, cells []draw.Cell) bool {
+	fmt.Fprintln(console)
+	width := 40
+	report := progressReporter()
+	err := repo.FastImportCells(cells, func(done, total int) {
+		report(done, total)
+		if !interactive || total == 0 {
+			return
+		}
+		pct := float64(done) / float64(total)
+		filled := int(pct * float64(width))
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+		fmt.Fprintf(console, "\r  %s%s%s %s%3d%%%s", cyan, bar, reset, dim, int(pct*100), reset)
+	})
+	if interactive {
+		fmt.Fprintln(console)
+	}
+
+	if err != nil {
+		result.Error = "commit generation failed: " + err.Error()
+		fmt.Fprintf(console, "\n  %s!%s %s\n", bold+yellow, reset, "commit generation failed: "+err.Error())
+		fmt.Fprintln(console, dim+"  Run again with the same design and directory to resume."+reset)
+		return false
+	}
+	return true
+}
+
+func configureRemote(repo *git.Repo, repoPath, defaultRemote string, push git.PushOptions) {
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, dim+"  Create an empty repo at github.com/new (no README)"+reset)
+	fmt.Fprintln(console)
 
-	remote := ask("GitHub URL (or enter to skip)")
+	var remote string
+	if defaultRemote != "" {
+		remote = askWithDefault("GitHub URL", defaultRemote)
+	} else {
+		remote = ask("GitHub URL (or enter to skip)")
+	}
 	if remote == "" {
 		printNextSteps(repoPath)
 		return
@@ -202,56 +484,76 @@ func configureRemote(repo *git.Repo, repoPath string) {
 		remote = "https://" + remote
 	}
 
-	if err := repo.AddRemote(remote); err != nil {
-		warn("failed to add remote")
+	result.Remote = remote
+	if err := repo.AddRemote(push.Remote, remote); err != nil {
+		result.Error = "failed to add remote: " + err.Error()
+		warn("failed to add remote: " + err.Error())
 		printNextSteps(repoPath)
 		return
 	}
 
-	fmt.Println()
-	spin("Pushing", func() error {
-		return repo.Push()
-	})
+	fmt.Fprintln(console)
+	if err := spin("Pushing", func() error {
+		return repo.Push(push)
+	}); err != nil {
+		result.Error = "push failed: " + err.Error()
+		warn(err.Error())
+		fmt.Fprintln(console)
+		return
+	}
+	result.Pushed = true
 
-	fmt.Println()
+	fmt.Fprintln(console)
 	success("Done")
-	fmt.Println()
+	fmt.Fprintln(console)
 }
 
 func printNextSteps(repoPath string) {
-	fmt.Println()
-	fmt.Println(dim + "  To push manually:" + reset)
-	fmt.Println()
-	fmt.Printf("    cd %s\n", repoPath)
-	fmt.Println("    git remote add origin <url>")
-	fmt.Println("    git push -u origin main")
-	fmt.Println()
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, dim+"  To push manually:"+reset)
+	fmt.Fprintln(console)
+	fmt.Fprintf(console, "    cd %s\n", repoPath)
+	fmt.Fprintln(console, "    git remote add origin <url>")
+	fmt.Fprintln(console, "    git push -u origin main")
+	fmt.Fprintln(console)
 }
 
 func printHeader() {
-	fmt.Println()
-	fmt.Println(bold + "  gitdraw" + reset + dim + " — contribution graph art" + reset)
-	fmt.Println()
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, bold+"  gitdraw"+reset+dim+" — contribution graph art"+reset)
+	fmt.Fprintln(console)
 }
 
-func printPreview(grid draw.Grid) {
-	fmt.Println(dim + "  Preview:" + reset)
-	fmt.Println()
-	for _, line := range strings.Split(grid.Render(), "\n") {
+func printPreview(cal draw.Calendar, grid draw.Grid) {
+	printGraph("Preview", cal, grid)
+}
+
+func printGraph(title string, cal draw.Calendar, grid draw.Grid) {
+	fmt.Fprintln(console, dim+"  "+title+":"+reset)
+	fmt.Fprintln(console)
+	for _, line := range strings.Split(grid.Render(cal), "\n") {
 		if line != "" {
-			fmt.Println("  " + line)
+			fmt.Fprintln(console, "  "+line)
 		}
 	}
 }
 
 func ask(prompt string) string {
-	fmt.Printf("  %s%s%s ", bold, prompt, reset)
+	fmt.Fprintf(console, "  %s%s%s ", bold, prompt, reset)
+	if unattended {
+		fmt.Fprintln(console)
+		return ""
+	}
 	text, _ := reader.ReadString('\n')
 	return strings.TrimSpace(text)
 }
 
 func askWithDefault(prompt, def string) string {
-	fmt.Printf("  %s%s%s %s(%s)%s ", bold, prompt, reset, dim, def, reset)
+	fmt.Fprintf(console, "  %s%s%s %s(%s)%s ", bold, prompt, reset, dim, def, reset)
+	if unattended {
+		fmt.Fprintln(console)
+		return def
+	}
 	text, _ := reader.ReadString('\n')
 	text = strings.TrimSpace(text)
 	if text == "" {
@@ -260,32 +562,50 @@ func askWithDefault(prompt, def string) string {
 	return text
 }
 
+// confirm asks a yes or no question. With --yes the answer is yes.
 func confirm(prompt string) bool {
-	fmt.Printf("  %s%s?%s %s(y/n)%s ", bold, prompt, reset, dim, reset)
+	fmt.Fprintf(console, "  %s%s?%s %s(y/n)%s ", bold, prompt, reset, dim, reset)
+	if unattended {
+		fmt.Fprintln(console, "y")
+		return true
+	}
 	text, _ := reader.ReadString('\n')
 	return strings.ToLower(strings.TrimSpace(text)) == "y"
 }
 
+// offer is confirm for something the run does not need, which --yes
+// declines.
+func offer(prompt string) bool {
+	if unattended {
+		fmt.Fprintf(console, "  %s%s?%s %s(y/n)%s n\n", bold, prompt, reset, dim, reset)
+		return false
+	}
+	return confirm(prompt)
+}
+
 func info(label, value string) {
-	fmt.Printf("  %s%s:%s %s\n", dim, label, reset, value)
+	fmt.Fprintf(console, "  %s%s:%s %s\n", dim, label, reset, value)
 }
 
 func success(msg string) {
-	fmt.Printf("  %s%s✓%s %s\n", bold, green, reset, msg)
+	fmt.Fprintf(console, "  %s%s✓%s %s\n", bold, green, reset, msg)
 }
 
 func warn(msg string) {
-	fmt.Printf("  %s%s!%s %s\n", bold, yellow, reset, msg)
+	emit(messageEvent{Event: "warning", Message: msg})
+	fmt.Fprintf(console, "  %s%s!%s %s\n", bold, yellow, reset, msg)
 }
 
 func exit(msg string) {
-	fmt.Println()
-	fmt.Printf("  %serror:%s %s\n", bold, reset, msg)
-	fmt.Println()
+	result.Error = msg
+	finish()
+	fmt.Fprintln(console)
+	fmt.Fprintf(console, "  %serror:%s %s\n", bold, reset, msg)
+	fmt.Fprintln(console)
 	os.Exit(1)
 }
 
-func spin(msg string, fn func() error) {
+func spin(msg string, fn func() error) error {
 	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 	done := make(chan error)
 
@@ -293,18 +613,26 @@ func spin(msg string, fn func() error) {
 		done <- fn()
 	}()
 
+	cr := ""
+	if interactive {
+		cr = "\r"
+	}
+
 	i := 0
 	for {
 		select {
 		case err := <-done:
+			emitStep(msg, err)
 			if err != nil {
-				fmt.Printf("\r  %s%s✗%s %s\n", bold, yellow, reset, msg)
-				return
+				fmt.Fprintf(console, "%s  %s%s✗%s %s\n", cr, bold, yellow, reset, msg)
+				return err
 			}
-			fmt.Printf("\r  %s%s✓%s %s\n", bold, green, reset, msg)
-			return
+			fmt.Fprintf(console, "%s  %s%s✓%s %s\n", cr, bold, green, reset, msg)
+			return nil
 		default:
-			fmt.Printf("\r  %s%s%s %s", cyan, frames[i%len(frames)], reset, msg)
+			if interactive {
+				fmt.Fprintf(console, "\r  %s%s%s %s", cyan, frames[i%len(frames)], reset, msg)
+			}
 			time.Sleep(80 * time.Millisecond)
 			i++
 		}
@@ -312,10 +640,8 @@ func spin(msg string, fn func() error) {
 }
 
 func clearScreen() {
-	fmt.Print("\033[H\033[2J")
-}
-
-func dirExists(path string) bool {
-	info, err := os.Stat(path)
-	return err == nil && info.IsDir()
+	if !interactive {
+		return
+	}
+	fmt.Fprint(console, "\033[H\033[2J")
 }
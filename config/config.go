@@ -0,0 +1,231 @@
+// Package config reads the defaults gitdraw uses for repeatable runs.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the config file looked for in the working directory.
+const FileName = "gitdraw.yaml"
+
+// Config holds defaults for a run. Zero values mean unset.
+type Config struct {
+	Year      int    `json:"year,omitempty"`
+	Intensity int    `json:"intensity,omitempty"`
+	Fill      *bool  `json:"fill,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Remote    string `json:"remote,omitempty"`
+	Font      string `json:"font,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Calendar  string `json:"calendar,omitempty"`
+	WeekStart string `json:"week_start,omitempty"`
+
+	// Path is the file the config was read from, empty if there was none.
+	Path string `json:"-"`
+}
+
+// Paths returns where Load looks for a config file, in order.
+func Paths() []string {
+	paths := []string{FileName}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		paths = append(paths, filepath.Join(dir, "gitdraw", "config.yaml"))
+	}
+	return paths
+}
+
+// Load reads the config at path, or if path is empty the first of Paths
+// that exists. Having no config file at all is not an error.
+func Load(path string) (Config, error) {
+	if path != "" {
+		return Read(path)
+	}
+	for _, p := range Paths() {
+		if _, err := os.Stat(p); err == nil {
+			return Read(p)
+		}
+	}
+	return Config{}, nil
+}
+
+func Read(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+	c.Path = path
+	return c, nil
+}
+
+// Parse reads the small part of YAML a config needs: one "key: value" per
+// line, # comments and optionally quoted values.
+func Parse(data []byte) (Config, error) {
+	var c Config
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "---" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return c, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		value, err := parseValue(strings.TrimSpace(value))
+		if err == nil {
+			err = c.Set(strings.TrimSpace(key), value)
+		}
+		if err != nil {
+			return c, fmt.Errorf("line %d: %v", i+1, err)
+		}
+	}
+	return c, nil
+}
+
+func parseValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := strings.LastIndex(s, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
+
+// Set sets a field by its key in the file, checking the value the same
+// way Parse does.
+func (c *Config) Set(key, value string) error {
+	var err error
+	switch key {
+	case "year":
+		c.Year, err = strconv.Atoi(value)
+	case "intensity":
+		c.Intensity, err = strconv.Atoi(value)
+	case "fill":
+		var fill bool
+		fill, err = parseBool(value)
+		c.Fill = &fill
+	case "author":
+		c.Author, c.Email = splitAuthor(value, c.Email)
+	case "email":
+		c.Email = value
+	case "remote":
+		c.Remote = value
+	case "font":
+		c.Font = value
+	case "timezone":
+		_, err = time.LoadLocation(value)
+		c.Timezone = value
+	case "output":
+		c.Output = value
+	case "text":
+		c.Text = value
+	case "calendar":
+		c.Calendar = value
+	case "week_start":
+		c.WeekStart = value
+	default:
+		return fmt.Errorf("unknown key: %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("bad %s: %s", key, value)
+	}
+	return nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("not a boolean")
+}
+
+// splitAuthor accepts either a plain name or "Name <email>".
+func splitAuthor(s, email string) (string, string) {
+	open, end := strings.Index(s, "<"), strings.LastIndex(s, ">")
+	if open < 0 || end < open {
+		return s, email
+	}
+	return strings.TrimSpace(s[:open]), strings.TrimSpace(s[open+1 : end])
+}
+
+// Override returns c with every field that is set in o replaced by o's
+// value, for flags that take precedence over the file.
+func (c Config) Override(o Config) Config {
+	if o.Year != 0 {
+		c.Year = o.Year
+	}
+	if o.Intensity != 0 {
+		c.Intensity = o.Intensity
+	}
+	if o.Fill != nil {
+		c.Fill = o.Fill
+	}
+	if o.Author != "" {
+		c.Author = o.Author
+	}
+	if o.Email != "" {
+		c.Email = o.Email
+	}
+	if o.Remote != "" {
+		c.Remote = o.Remote
+	}
+	if o.Font != "" {
+		c.Font = o.Font
+	}
+	if o.Timezone != "" {
+		c.Timezone = o.Timezone
+	}
+	if o.Output != "" {
+		c.Output = o.Output
+	}
+	if o.Text != "" {
+		c.Text = o.Text
+	}
+	if o.Calendar != "" {
+		c.Calendar = o.Calendar
+	}
+	if o.WeekStart != "" {
+		c.WeekStart = o.WeekStart
+	}
+	return c
+}
+
+// Location returns the configured timezone, or nil if none is set.
+func (c Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return nil
+	}
+	loc, _ := time.LoadLocation(c.Timezone)
+	return loc
+}
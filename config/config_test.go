@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(`
+# team banner
+year: 2024
+intensity: 20   # darker
+fill: yes
+author: Jane Doe <jane@example.com>
+timezone: Europe/Berlin
+text: "HIRE ME {yy}"
+output: 'it''s here'
+calendar: gitlab-monday
+week_start: monday
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Year != 2024 || c.Intensity != 20 {
+		t.Errorf("got year %d intensity %d", c.Year, c.Intensity)
+	}
+	if c.Fill == nil || !*c.Fill {
+		t.Error("fill not set")
+	}
+	if c.Author != "Jane Doe" || c.Email != "jane@example.com" {
+		t.Errorf("got author %q <%q>", c.Author, c.Email)
+	}
+	if c.Text != "HIRE ME {yy}" || c.Output != "it's here" {
+		t.Errorf("got text %q output %q", c.Text, c.Output)
+	}
+	if c.Location() == nil {
+		t.Error("timezone not loaded")
+	}
+	if c.Calendar != "gitlab-monday" || c.WeekStart != "monday" {
+		t.Errorf("got calendar %q week start %q", c.Calendar, c.WeekStart)
+	}
+
+	for _, bad := range []string{"colour: red", "year: soon", "fill: maybe", "timezone: Mars/Base", "just text"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	c, err := Load("")
+	if err != nil || c.Path != "" {
+		t.Fatalf("Load without a file gave %+v, %v", c, err)
+	}
+
+	path := filepath.Join(dir, "gitdraw", "config.yaml")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("intensity: 7\n"), 0644)
+
+	c, err = Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Path != path || c.Intensity != 7 {
+		t.Errorf("got %+v from %s", c, c.Path)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("missing explicit config accepted")
+	}
+}
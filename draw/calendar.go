@@ -0,0 +1,170 @@
+package draw
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Calendar is a platform's contribution calendar: which dates the cells of
+// the grid stand for, and how they are shaded. Every calendar is Rows days
+// by Weeks weeks, with the day that ends the window in the last column.
+type Calendar interface {
+	Name() string
+	// FirstDay is the weekday of the top row.
+	FirstDay() time.Weekday
+	// Window is the first and last day shown for year. Cells outside it
+	// are never drawn by the platform.
+	Window(year int) (from, to time.Time)
+	// Colors are the platform's shades for levels 0 to MaxLevel.
+	Colors() [MaxLevel + 1]string
+}
+
+// GitHub shows a calendar year. Its weeks start on Sunday; WeekStart lays
+// the grid out for dashboards that mirror it with another first day.
+type GitHub struct {
+	WeekStart time.Weekday
+}
+
+func (c GitHub) Name() string               { return named("github", c.WeekStart) }
+func (c GitHub) FirstDay() time.Weekday     { return c.WeekStart }
+func (GitHub) Colors() [MaxLevel + 1]string { return Colors }
+
+func (GitHub) Window(year int) (time.Time, time.Time) {
+	return time.Date(year, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(year, 12, 31, 12, 0, 0, 0, time.UTC)
+}
+
+// GitLab shows the last year up to today, starting the weeks on the day
+// the user picked in their preferences.
+type GitLab struct {
+	WeekStart time.Weekday
+}
+
+func (c GitLab) Name() string                         { return named("gitlab", c.WeekStart) }
+func (c GitLab) FirstDay() time.Weekday               { return c.WeekStart }
+func (GitLab) Window(year int) (time.Time, time.Time) { return lastYear(year) }
+
+func (GitLab) Colors() [MaxLevel + 1]string {
+	return [MaxLevel + 1]string{"#ededed", "#acd5f2", "#7fa8c9", "#527ba0", "#254e77"}
+}
+
+// Gitea's heatmap shows the last year up to today, starting the weeks on
+// Sunday unless WeekStart says otherwise.
+type Gitea struct {
+	WeekStart time.Weekday
+}
+
+func (c Gitea) Name() string                         { return named("gitea", c.WeekStart) }
+func (c Gitea) FirstDay() time.Weekday               { return c.WeekStart }
+func (Gitea) Window(year int) (time.Time, time.Time) { return lastYear(year) }
+
+func (Gitea) Colors() [MaxLevel + 1]string {
+	return [MaxLevel + 1]string{"#ebebeb", "#a0c1e2", "#6b9fd3", "#4183c4", "#2c5f93"}
+}
+
+// lastYear is the window of a calendar that shows the year up to today.
+// For other years it is the window as it is on the last day of that year.
+func lastYear(year int) (time.Time, time.Time) {
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.UTC)
+	if year != now.Year() {
+		to = time.Date(year, 12, 31, 12, 0, 0, 0, time.UTC)
+	}
+	return to.AddDate(-1, 0, 1), to
+}
+
+// Rolling reports whether cal only shows the year up to today, so a design
+// for an earlier year is not visible.
+func Rolling(cal Calendar) bool {
+	_, ok := cal.(GitHub)
+	return !ok
+}
+
+// Default reports whether cal is GitHub's calendar as GitHub draws it.
+func Default(cal Calendar) bool {
+	return cal == Calendar(GitHub{})
+}
+
+// named is a calendar's name, with the first weekday when it is not
+// Sunday.
+func named(platform string, first time.Weekday) string {
+	if first == time.Sunday {
+		return platform
+	}
+	return platform + "-" + strings.ToLower(first.String())
+}
+
+// Calendars lists the names ParseCalendar accepts. Each can also be
+// followed by a first weekday, as in github-monday.
+var Calendars = []string{"github", "gitlab", "gitlab-monday", "gitea"}
+
+func ParseCalendar(name string) (Calendar, error) {
+	platform, day, _ := strings.Cut(strings.ToLower(name), "-")
+	first := time.Sunday
+	if day != "" {
+		var err error
+		if first, err = ParseWeekday(day); err != nil {
+			return nil, fmt.Errorf("calendar %q: %v", name, err)
+		}
+	}
+	switch platform {
+	case "", "github":
+		return GitHub{WeekStart: first}, nil
+	case "gitlab":
+		return GitLab{WeekStart: first}, nil
+	case "gitea":
+		return Gitea{WeekStart: first}, nil
+	}
+	return nil, fmt.Errorf("unknown calendar %q (have %s)", name, strings.Join(Calendars, ", "))
+}
+
+// ParseWeekday reads a day's English name or its first three letters.
+func ParseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(name)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// StartWeek returns cal with its weeks starting on first.
+func StartWeek(cal Calendar, first time.Weekday) Calendar {
+	switch c := cal.(type) {
+	case GitHub:
+		c.WeekStart = first
+		return c
+	case GitLab:
+		c.WeekStart = first
+		return c
+	case Gitea:
+		c.WeekStart = first
+		return c
+	}
+	return cal
+}
+
+// Start is the date of the top left cell of cal's graph for year.
+func Start(cal Calendar, year int) time.Time {
+	_, to := cal.Window(year)
+	back := (int(to.Weekday()) - int(cal.FirstDay()) + 7) % 7
+	return to.AddDate(0, 0, -back-(Weeks-1)*7)
+}
+
+// shown reports whether a date is in cal's window for year and not after
+// until, usually now.
+func shown(cal Calendar, year int, d, until time.Time) bool {
+	from, to := cal.Window(year)
+	return !d.Before(from) && !d.After(to) && !d.After(until)
+}
+
+// Weekdays names the rows of cal's graph, top to bottom.
+func Weekdays(cal Calendar) [Rows]time.Weekday {
+	var days [Rows]time.Weekday
+	for row := range days {
+		days[row] = (cal.FirstDay() + time.Weekday(row)) % 7
+	}
+	return days
+}
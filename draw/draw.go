@@ -3,6 +3,7 @@ package draw
 import (
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/1etu/gitdraw/font"
 )
@@ -15,34 +16,97 @@ const (
 type Grid [Rows][Weeks]int
 
 type Point struct {
-	Week int
-	Day  int
+	Week  int
+	Day   int
+	Level int
 }
 
+// Cell is a single graph square scheduled for commits.
+type Cell struct {
+	Week    int
+	Day     int
+	Level   int
+	Date    time.Time
+	Commits int
+}
+
+// Text draws text in the built-in font, starting at the second column.
+// A sprite name between colons, as in ":heart:", draws that sprite.
 func Text(text string) Grid {
 	var grid Grid
-	col := 1
-
-	for _, ch := range text {
-		if col >= Weeks {
+	for i, col := range Strip(text) {
+		if 1+i >= Weeks {
 			break
 		}
+		for y, level := range col {
+			grid[y][1+i] = level
+		}
+	}
+	return grid
+}
 
+// Strip draws text like Text, but as wide as it takes: one column per
+// week, each glyph or sprite followed by an empty column.
+func Strip(text string) [][Rows]int {
+	var cols [][Rows]int
+
+	for i := 0; i < len(text); {
+		if s, n, ok := spriteAt(text[i:]); ok {
+			for x := 0; x < s.Width(); x++ {
+				var col [Rows]int
+				for y := range col {
+					col[y] = s.Level(x, y)
+				}
+				cols = append(cols, col)
+			}
+			cols = append(cols, [Rows]int{})
+			i += n
+			continue
+		}
+
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		i += size
 		glyph := font.Get(ch)
 		for x := 0; x < font.Width(); x++ {
-			if col+x >= Weeks {
-				break
-			}
+			var col [Rows]int
 			for y := 0; y < font.Height(); y++ {
 				if glyph[y]&(1<<x) != 0 {
-					grid[y][col+x] = 1
+					col[y] = MaxLevel
 				}
 			}
+			cols = append(cols, col)
 		}
-		col += font.Width() + 1
+		cols = append(cols, [Rows]int{})
 	}
 
-	return grid
+	return cols
+}
+
+// spriteAt reads a ":name:" sprite at the start of text, returning it and
+// the bytes it takes.
+func spriteAt(text string) (font.Sprite, int, bool) {
+	if !strings.HasPrefix(text, ":") {
+		return font.Sprite{}, 0, false
+	}
+	name, _, ok := strings.Cut(text[1:], ":")
+	if !ok {
+		return font.Sprite{}, 0, false
+	}
+	s, ok := font.GetSprite(name)
+	return s, len(name) + 2, ok
+}
+
+// Stamp draws a sprite with its left edge at week, over what is there,
+// and returns its width.
+func (g *Grid) Stamp(s font.Sprite, week int) int {
+	for y := 0; y < Rows; y++ {
+		for x := 0; x < s.Width(); x++ {
+			if level := s.Level(x, y); level > 0 {
+				g.Set(week+x, y, level)
+			}
+		}
+	}
+	return s.Width()
 }
 
 func (g Grid) Points() []Point {
@@ -50,47 +114,52 @@ func (g Grid) Points() []Point {
 	for week := 0; week < Weeks; week++ {
 		for day := 0; day < Rows; day++ {
 			if g[day][week] > 0 {
-				pts = append(pts, Point{Week: week, Day: day})
+				pts = append(pts, Point{Week: week, Day: day, Level: g[day][week]})
 			}
 		}
 	}
 	return pts
 }
 
-func graphStart(year int) time.Time {
-	dec31 := time.Date(year, 12, 31, 12, 0, 0, 0, time.UTC)
-	
-	daysSinceSunday := int(dec31.Weekday())
-	lastSunday := dec31.AddDate(0, 0, -daysSinceSunday)
-	
-	return lastSunday.AddDate(0, 0, -52*7)
+func TotalCommits(cells []Cell) int {
+	total := 0
+	for _, c := range cells {
+		total += c.Commits
+	}
+	return total
 }
 
-func (g Grid) Dates(year int) []time.Time {
-	start := graphStart(year)
-	now := time.Now()
+// Locate returns the position of d within cal's graph for year.
+func Locate(cal Calendar, year int, d time.Time) Point {
+	start := Start(cal, year)
+	day := time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.UTC)
+	days := int(day.Sub(start).Hours() / 24)
+	return Point{Week: days / 7, Day: days % 7}
+}
+
+func (g Grid) Dates(cal Calendar, year int) []time.Time {
+	start := Start(cal, year)
 	pts := g.Points()
 	dates := make([]time.Time, 0, len(pts))
 
 	for _, p := range pts {
 		d := start.AddDate(0, 0, p.Week*7+p.Day)
-		if d.Year() == year && !d.After(now) {
+		if shown(cal, year, d, time.Now()) {
 			dates = append(dates, d)
 		}
 	}
 	return dates
 }
 
-func (g Grid) BackgroundDates(year int) []time.Time {
-	start := graphStart(year)
-	now := time.Now()
+func (g Grid) BackgroundDates(cal Calendar, year int) []time.Time {
+	start := Start(cal, year)
 	var dates []time.Time
 
 	for week := 0; week < Weeks; week++ {
 		for day := 0; day < Rows; day++ {
 			if g[day][week] == 0 {
 				d := start.AddDate(0, 0, week*7+day)
-				if d.Year() == year && !d.After(now) {
+				if shown(cal, year, d, time.Now()) {
 					dates = append(dates, d)
 				}
 			}
@@ -99,6 +168,61 @@ func (g Grid) BackgroundDates(year int) []time.Time {
 	return dates
 }
 
+// Cells schedules commits for every painted cell shown in cal's graph for
+// year, scaling intensity by the cell's level so level 4 gets the full
+// intensity.
+func (g Grid) Cells(cal Calendar, year, intensity int) []Cell {
+	return g.CellsUntil(cal, year, intensity, time.Now())
+}
+
+// CellsUntil is Cells as of until, which may be a day to come.
+func (g Grid) CellsUntil(cal Calendar, year, intensity int, until time.Time) []Cell {
+	start := Start(cal, year)
+	var cells []Cell
+
+	for _, p := range g.Points() {
+		d := start.AddDate(0, 0, p.Week*7+p.Day)
+		if !shown(cal, year, d, until) {
+			continue
+		}
+		level := p.Level
+		if level > MaxLevel {
+			level = MaxLevel
+		}
+		commits := intensity * level / MaxLevel
+		if commits < 1 {
+			commits = 1
+		}
+		cells = append(cells, Cell{Week: p.Week, Day: p.Day, Level: level, Date: d, Commits: commits})
+	}
+	return cells
+}
+
+// BackgroundCells schedules commits for every empty cell shown in cal's
+// graph for year.
+func (g Grid) BackgroundCells(cal Calendar, year, commits int) []Cell {
+	return g.BackgroundCellsUntil(cal, year, commits, time.Now())
+}
+
+// BackgroundCellsUntil is BackgroundCells as of until.
+func (g Grid) BackgroundCellsUntil(cal Calendar, year, commits int, until time.Time) []Cell {
+	start := Start(cal, year)
+	var cells []Cell
+
+	for week := 0; week < Weeks; week++ {
+		for day := 0; day < Rows; day++ {
+			if g[day][week] != 0 {
+				continue
+			}
+			d := start.AddDate(0, 0, week*7+day)
+			if shown(cal, year, d, until) {
+				cells = append(cells, Cell{Week: week, Day: day, Date: d, Commits: commits})
+			}
+		}
+	}
+	return cells
+}
+
 func AllDates(year int) []time.Time {
 	start := time.Date(year, 1, 1, 12, 0, 0, 0, time.UTC)
 	end := time.Date(year, 12, 31, 12, 0, 0, 0, time.UTC)
@@ -113,17 +237,23 @@ func AllDates(year int) []time.Time {
 	return dates
 }
 
-func (g Grid) Render() string {
+// Render draws the grid as text, labelling the rows with cal's weekdays.
+func (g Grid) Render(cal Calendar) string {
 	var sb strings.Builder
-	days := []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	days := Weekdays(cal)
 
 	for row := 0; row < Rows; row++ {
-		sb.WriteString(days[row])
+		sb.WriteString(days[row].String()[:3])
 		sb.WriteString(" ")
 		for col := 0; col < Weeks; col++ {
-			if g[row][col] > 0 {
+			switch level := g[row][col]; {
+			case level >= MaxLevel:
 				sb.WriteString("██")
-			} else {
+			case level == 3:
+				sb.WriteString("▓▓")
+			case level > 0:
+				sb.WriteString("▒▒")
+			default:
 				sb.WriteString("░░")
 			}
 		}
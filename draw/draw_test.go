@@ -0,0 +1,447 @@
+package draw
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/font"
+)
+
+func count(g Grid, level int) int {
+	n := 0
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			if g[d][w] == level {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestLine(t *testing.T) {
+	var g Grid
+	g.Line(0, 0, 6, 6, 2)
+	for i := 0; i <= 6; i++ {
+		if g[i][i] != 2 {
+			t.Errorf("diagonal cell %d not painted", i)
+		}
+	}
+	if n := count(g, 2); n != 7 {
+		t.Errorf("painted %d cells, want 7", n)
+	}
+}
+
+func TestRect(t *testing.T) {
+	var outline, filled Grid
+	outline.Rect(10, 1, 14, 5, 3, false)
+	filled.Rect(14, 5, 10, 1, 3, true)
+
+	if n := count(outline, 3); n != 16 {
+		t.Errorf("outline painted %d cells, want 16", n)
+	}
+	if n := count(filled, 3); n != 25 {
+		t.Errorf("filled painted %d cells, want 25", n)
+	}
+	if outline[3][12] != 0 {
+		t.Error("outline painted its centre")
+	}
+}
+
+func TestEllipseClipsAtEdges(t *testing.T) {
+	var g Grid
+	g.Ellipse(0, 3, 4, 3, 1, true)
+	if g[3][0] != 1 || g[3][4] != 1 {
+		t.Error("ellipse missing its centre row")
+	}
+	if g[3][5] != 0 {
+		t.Error("ellipse wider than its radius")
+	}
+}
+
+func TestFill(t *testing.T) {
+	var g Grid
+	g.Rect(10, 0, 20, 6, 4, false)
+	g.Fill(15, 3, 2)
+
+	if n := count(g, 2); n != 9*5 {
+		t.Errorf("filled %d cells, want %d", n, 9*5)
+	}
+	if g[3][0] != 0 {
+		t.Error("fill leaked outside the rectangle")
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	for _, name := range Patterns {
+		a, ok := Pattern(name, 7)
+		if !ok {
+			t.Fatalf("pattern %s missing", name)
+		}
+		b, _ := Pattern(name, 7)
+		if a != b {
+			t.Errorf("pattern %s is not deterministic for a seed", name)
+		}
+		if count(a, 0) == Rows*Weeks {
+			t.Errorf("pattern %s is empty", name)
+		}
+	}
+
+	g := Gradient(1, 4, false)
+	if g[0][0] != 1 || g[0][Weeks-1] != 4 {
+		t.Errorf("gradient runs %d..%d, want 1..4", g[0][0], g[0][Weeks-1])
+	}
+}
+
+func TestCellsScaleByLevel(t *testing.T) {
+	var g Grid
+	g.Set(10, 3, 4)
+	g.Set(11, 3, 2)
+	g.Set(12, 3, 1)
+
+	cells := g.Cells(GitHub{}, 2020, 8)
+	want := map[int]int{4: 8, 2: 4, 1: 2}
+	if len(cells) != 3 {
+		t.Fatalf("got %d cells, want 3", len(cells))
+	}
+	for _, c := range cells {
+		if c.Commits != want[c.Level] {
+			t.Errorf("level %d got %d commits, want %d", c.Level, c.Commits, want[c.Level])
+		}
+		if p := Locate(GitHub{}, 2020, c.Date); p.Week != c.Week || p.Day != c.Day {
+			t.Errorf("Locate(%s) = %+v, want week %d day %d", c.Date, p, c.Week, c.Day)
+		}
+	}
+
+	if n := len(g.BackgroundCells(GitHub{}, 2020, 1)); n != 366-3 {
+		t.Errorf("got %d background cells, want %d", n, 366-3)
+	}
+}
+
+func TestCalendars(t *testing.T) {
+	// GitHub's 2020 graph starts on the Sunday 52 weeks before the last
+	// Sunday of the year
+	if got := Start(GitHub{}, 2020).Format("2006-01-02 Mon"); got != "2019-12-29 Sun" {
+		t.Errorf("github start = %s", got)
+	}
+
+	year := time.Now().Year() - 1
+	for _, name := range append(Calendars, "github-monday", "gitea-saturday") {
+		cal, err := ParseCalendar(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cal.Name() != name {
+			t.Errorf("ParseCalendar(%q).Name() = %q", name, cal.Name())
+		}
+		start := Start(cal, year)
+		if start.Weekday() != cal.FirstDay() {
+			t.Errorf("%s starts on a %s, want %s", name, start.Weekday(), cal.FirstDay())
+		}
+		_, to := cal.Window(year)
+		if p := Locate(cal, year, to); p.Week != Weeks-1 {
+			t.Errorf("%s: last day in week %d, want the last column", name, p.Week)
+		}
+
+		var full Grid
+		full.Rect(0, 0, Weeks-1, Rows-1, 1, true)
+		for _, c := range full.Cells(cal, year, 1) {
+			if d := Start(cal, year).AddDate(0, 0, c.Week*7+c.Day); !d.Equal(c.Date) {
+				t.Fatalf("%s: cell %d,%d is %s, want %s", name, c.Week, c.Day, c.Date, d)
+			}
+			if c.Date.After(to) {
+				t.Fatalf("%s: %s is after the window", name, c.Date)
+			}
+		}
+		if n := len(full.Cells(cal, year, 1)); n < 364 || n > 366 {
+			t.Errorf("%s shows %d days", name, n)
+		}
+	}
+
+	monday, _ := ParseCalendar("gitlab-monday")
+	if days := Weekdays(monday); days[0] != time.Monday || days[6] != time.Sunday {
+		t.Errorf("gitlab-monday rows = %v", days)
+	}
+	if got := strings.Fields(Grid{}.Render(monday))[0]; got != "Mon" {
+		t.Errorf("gitlab-monday render starts with %q", got)
+	}
+	if _, err := ParseCalendar("bitbucket"); err == nil {
+		t.Error("unknown calendar accepted")
+	}
+	if _, err := ParseCalendar("github-funday"); err == nil {
+		t.Error("unknown weekday accepted")
+	}
+	if cal, _ := ParseCalendar("GitLab-Sun"); cal.Name() != "gitlab" {
+		t.Errorf("gitlab-sun is %s", cal.Name())
+	}
+
+	// a Monday-first GitHub graph moves Sundays to the bottom row
+	github := StartWeek(GitHub{}, time.Monday)
+	if Default(github) || !Default(GitHub{}) {
+		t.Error("Default is wrong about the first weekday")
+	}
+	if got := Start(github, 2020).Format("2006-01-02 Mon"); got != "2019-12-30 Mon" {
+		t.Errorf("github-monday start = %s", got)
+	}
+	newYear := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	if p, q := Locate(GitHub{}, 2023, newYear), Locate(github, 2023, newYear); p.Day != 0 || q.Day != 6 {
+		t.Errorf("2023-01-01 at %+v on github, %+v on github-monday", p, q)
+	}
+}
+
+func TestShift(t *testing.T) {
+	var g Grid
+	g.Set(Weeks-1, 6, 3)
+
+	clipped := g.Shift(1, 0, false)
+	if n := count(clipped, 3); n != 0 {
+		t.Errorf("clipped shift kept %d cells", n)
+	}
+	wrapped := g.Shift(1, 1, true)
+	if wrapped[0][0] != 3 {
+		t.Error("wrapped shift did not come back at the origin")
+	}
+}
+
+func TestMirrorAndRotate(t *testing.T) {
+	var g Grid
+	g.Set(2, 1, 4)
+
+	if g.MirrorH().At(Weeks-3, 1) != 4 {
+		t.Error("MirrorH misplaced the cell")
+	}
+	if g.MirrorV().At(2, Rows-2) != 4 {
+		t.Error("MirrorV misplaced the cell")
+	}
+	if g.Rotate().At(Weeks-3, Rows-2) != 4 {
+		t.Error("Rotate misplaced the cell")
+	}
+	if g.Rotate().Rotate() != g {
+		t.Error("two half turns are not the identity")
+	}
+
+	var bar Grid
+	bar.Line(4, 2, 7, 2, 3)
+	right := bar.Turn(1)
+	for d := 2; d < 6; d++ {
+		if right.At(4, d) != 3 {
+			t.Errorf("quarter turn left week 4 day %d empty", d)
+		}
+	}
+	if right.Turn(-1) != bar {
+		t.Error("a quarter turn and back is not the identity")
+	}
+	if bar.Turn(2) != bar.Rotate() {
+		t.Error("two quarter turns are not a half turn")
+	}
+	// a bar longer than the graph is high loses what falls off the bottom
+	var long Grid
+	long.Line(0, 0, 9, 0, 1)
+	if n := count(long.Turn(1), 1); n != Rows {
+		t.Errorf("turned long bar kept %d cells, want %d", n, Rows)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	var g Grid
+	g.Set(0, 0, 1)
+	g.Set(1, 0, 4)
+
+	inv := g.Invert()
+	if inv.At(0, 0) != 3 || inv.At(1, 0) != 0 || inv.At(2, 0) != MaxLevel {
+		t.Errorf("Invert gave %d %d %d", inv.At(0, 0), inv.At(1, 0), inv.At(2, 0))
+	}
+
+	half := g.Scale(0.25)
+	if half.At(0, 0) != 1 || half.At(1, 0) != 1 || half.At(2, 0) != 0 {
+		t.Errorf("Scale(0.25) gave %d %d %d", half.At(0, 0), half.At(1, 0), half.At(2, 0))
+	}
+}
+
+func TestCropPaste(t *testing.T) {
+	g := Text("A")
+	piece := g.Crop(1, 0, 5, 6)
+
+	var out Grid
+	out.Paste(piece, 20, 0)
+	if out.Shift(-19, 0, false) != g {
+		t.Error("crop and paste did not preserve the glyph")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	g := Text("HI")
+	got, err := Transform(g, "scroll:10, mirror-h, mirror-h, scroll:-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != g {
+		t.Error("round trip transform changed the grid")
+	}
+	for _, ops := range []string{"spin", "shift:3x", "shift:", "scroll:1:2:3", "rotate:45", "crop:1:2", "copy:0:0:1:1:2", "scale:", "scale:0", "scale:-1", "scale:NaN", "scale:Inf"} {
+		if _, err := Transform(g, ops); err == nil {
+			t.Errorf("%q accepted", ops)
+		}
+	}
+
+	var l Grid
+	l.Line(3, 1, 3, 4, 2)
+	l.Line(3, 4, 5, 4, 2)
+	got, err = Transform(l, "rotate:90, rotate:270")
+	if err != nil || got != l {
+		t.Errorf("rotate:90,rotate:270 = %v, changed %v", err, got != l)
+	}
+	got, err = Transform(g, "crop:0:0:4:6, copy:0:0:4:6:10:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Crop(10, 0, 14, 6) != g.Crop(0, 0, 4, 6) || got.At(6, 0) != 0 {
+		t.Error("crop and copy did not keep the first glyph alone, twice")
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	var bg, fg Grid
+	bg.Rect(0, 0, 2, 0, 3, true)
+	fg.Set(0, 0, 1)
+	fg.Set(1, 0, 2)
+
+	cases := []struct {
+		blend Blend
+		want  [3]int
+	}{
+		{BlendMax, [3]int{3, 3, 3}},
+		{BlendAdd, [3]int{4, 4, 3}},
+		{BlendReplace, [3]int{1, 2, 3}},
+	}
+	for _, c := range cases {
+		g := Flatten(Layer{Grid: bg}, Layer{Grid: fg, Blend: c.blend})
+		got := [3]int{g.At(0, 0), g.At(1, 0), g.At(2, 0)}
+		if got != c.want {
+			t.Errorf("%s gave %v, want %v", c.blend, got, c.want)
+		}
+	}
+
+	levels, err := ParseLevels("0,1,1,2,2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := Flatten(Layer{Grid: bg, Levels: levels}); g.At(0, 0) != 2 {
+		t.Errorf("mapped level %d, want 2", g.At(0, 0))
+	}
+	if _, err := ParseLevels("0,1,2"); err == nil {
+		t.Error("short level mapping accepted")
+	}
+}
+
+func TestFromImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, Weeks*2, Rows*2))
+	for y := 0; y < Rows*2; y++ {
+		for x := 0; x < Weeks*2; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	// one black cell at week 3, day 2
+	for y := 4; y < 6; y++ {
+		for x := 6; x < 8; x++ {
+			img.SetGray(x, y, color.Gray{})
+		}
+	}
+
+	g := FromImage(img, false)
+	if g.At(3, 2) != MaxLevel {
+		t.Errorf("dark cell got level %d", g.At(3, 2))
+	}
+	if n := count(g, 0); n != Rows*Weeks-1 {
+		t.Errorf("%d empty cells, want %d", n, Rows*Weeks-1)
+	}
+	if inv := FromImage(img, true); inv.At(3, 2) != 0 || inv.At(0, 0) != MaxLevel {
+		t.Error("invert did not swap light and dark")
+	}
+}
+
+func TestSprites(t *testing.T) {
+	for _, name := range font.SpriteNames() {
+		s, _ := font.GetSprite(name)
+		if s.Width() == 0 || s.Width() > Weeks {
+			t.Errorf("%s is %d wide", name, s.Width())
+		}
+		for y, row := range s {
+			if strings.Trim(row, ".1234#") != "" {
+				t.Errorf("%s row %d has a bad cell: %q", name, y, row)
+			}
+		}
+	}
+
+	heart, _ := font.GetSprite("heart")
+	var want Grid
+	want.Stamp(heart, 1)
+	if g := Text(":HEART:"); g != want {
+		t.Errorf(":HEART: drew\n%s", g.Render(GitHub{}))
+	}
+
+	// the text after a sprite starts one column past it
+	g := Text(":heart:I")
+	if col := 1 + heart.Width() + 1; g.At(col, 0) != 0 || g.At(col+1, 0) != MaxLevel {
+		t.Errorf("text after the sprite is misplaced\n%s", g.Render(GitHub{}))
+	}
+
+	// an unknown name is plain text, starting with the colon
+	if g := Text(":NOPE:"); g.At(3, 1) != MaxLevel || g.At(3, 0) != 0 {
+		t.Errorf(":NOPE: is not drawn as text\n%s", g.Render(GitHub{}))
+	}
+}
+
+func TestTransformLayers(t *testing.T) {
+	var a, b Grid
+	a.Set(10, 0, 1)
+	b.Set(12, 2, 2)
+	layers := []Layer{{Name: "a", Grid: a}, {Name: "b", Grid: b}}
+	if err := TransformLayers(layers, "rotate:90"); err != nil {
+		t.Fatal(err)
+	}
+	// both turn about week 10, day 0, the corner of the box around them
+	if layers[0].Grid.At(12, 0) != 1 || layers[1].Grid.At(10, 2) != 2 {
+		t.Errorf("layers turned apart:\n%v\n%v", layers[0].Grid.Points(), layers[1].Grid.Points())
+	}
+	if err := TransformLayers(layers, "shift:1x"); err == nil {
+		t.Error("bad op accepted")
+	}
+}
+
+func TestTransformFlatten(t *testing.T) {
+	var a, b Grid
+	a.Rect(2, 1, 6, 4, 1, true)
+	b.Line(4, 0, 9, 5, 3)
+	layers := func() []Layer {
+		return []Layer{{Name: "a", Grid: a}, {Name: "b", Grid: b}}
+	}
+
+	// transforming the layers and flattening them draws the same as
+	// flattening first
+	for _, ops := range []string{"shift:3:1", "scroll:-5", "mirror-h", "mirror-v", "rotate:90", "invert", "scale:0.5", "crop:3:0:8:5", "invert, mirror-h, invert"} {
+		ls := layers()
+		if err := TransformLayers(ls, ops); err != nil {
+			t.Fatal(err)
+		}
+		want, _ := Transform(Flatten(layers()...), ops)
+		if got := Flatten(ls...); got != want {
+			t.Errorf("%s: flattening does not commute", ops)
+		}
+	}
+
+	// invert takes the levels and blends of the layers into account
+	ls := layers()
+	ls[0].Levels = []int{0, 2, 2, 2, 2}
+	ls[1].Blend = BlendAdd
+	want := Flatten(ls...).Invert()
+	if err := TransformLayers(ls, "invert"); err != nil {
+		t.Fatal(err)
+	}
+	if Flatten(ls...) != want || ls[1].Grid != (Grid{}) {
+		t.Error("invert did not invert the flattened layers")
+	}
+}
@@ -0,0 +1,46 @@
+package draw
+
+import (
+	"image"
+	"math"
+)
+
+// FromImage scales img down to the graph and turns brightness into
+// levels, dark pixels becoming high levels, or light ones if invert is
+// set. Transparent pixels stay empty.
+func FromImage(img image.Image, invert bool) Grid {
+	var g Grid
+	b := img.Bounds()
+	if b.Empty() {
+		return g
+	}
+
+	for row := 0; row < Rows; row++ {
+		y0 := b.Min.Y + row*b.Dy()/Rows
+		y1 := max(b.Min.Y+(row+1)*b.Dy()/Rows, y0+1)
+		for col := 0; col < Weeks; col++ {
+			x0 := b.Min.X + col*b.Dx()/Weeks
+			x1 := max(b.Min.X+(col+1)*b.Dx()/Weeks, x0+1)
+
+			// average the ink of every pixel in the cell, weighting by
+			// alpha so transparent areas count as empty
+			var ink, n float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, gr, bl, a := img.At(x, y).RGBA()
+					n++
+					if a == 0 {
+						continue
+					}
+					lum := (0.299*float64(r) + 0.587*float64(gr) + 0.114*float64(bl)) / float64(a)
+					if !invert {
+						lum = 1 - lum
+					}
+					ink += lum * float64(a) / 0xffff
+				}
+			}
+			g[row][col] = clampLevel(int(math.Round(ink / n * MaxLevel)))
+		}
+	}
+	return g
+}
@@ -0,0 +1,101 @@
+package draw
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Blend string
+
+const (
+	BlendMax     Blend = "max"
+	BlendAdd     Blend = "add"
+	BlendReplace Blend = "replace"
+)
+
+func ParseBlend(s string) (Blend, error) {
+	switch b := Blend(s); b {
+	case BlendMax, BlendAdd, BlendReplace:
+		return b, nil
+	case "":
+		return BlendMax, nil
+	}
+	return "", fmt.Errorf("unknown blend mode: %s", s)
+}
+
+// Layer is one named grid in a layered design. Levels maps the layer's own
+// levels to output levels before blending, so a background can be drawn
+// with levels 1-4 and still come out faint; nil keeps levels as they are.
+type Layer struct {
+	Name   string `json:"name"`
+	Grid   Grid   `json:"grid"`
+	Levels []int  `json:"levels,omitempty"`
+	Blend  Blend  `json:"blend,omitempty"`
+}
+
+// At is the level the layer paints a cell with, after its level mapping.
+func (l Layer) At(week, day int) int {
+	return l.level(l.Grid.At(week, day))
+}
+
+func (l Layer) level(v int) int {
+	if v <= 0 {
+		return 0
+	}
+	v = clampLevel(v)
+	if v < len(l.Levels) {
+		return clampLevel(l.Levels[v])
+	}
+	return v
+}
+
+// Flatten composites layers bottom to top into a single level grid. Only
+// painted cells of a layer take part in blending:
+//
+//	max      keep the higher of the two levels
+//	add      sum the levels, capped at MaxLevel
+//	replace  the layer wins wherever it is painted
+func Flatten(layers ...Layer) Grid {
+	var out Grid
+	for _, l := range layers {
+		for d := 0; d < Rows; d++ {
+			for w := 0; w < Weeks; w++ {
+				if l.Grid[d][w] <= 0 {
+					continue
+				}
+				v := l.level(l.Grid[d][w])
+				switch l.Blend {
+				case BlendAdd:
+					out[d][w] = clampLevel(out[d][w] + v)
+				case BlendReplace:
+					out[d][w] = v
+				default:
+					out[d][w] = max(out[d][w], v)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ParseLevels reads a level mapping such as "0,1,1,2,2": the output level
+// for each input level from 0 to MaxLevel.
+func ParseLevels(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != MaxLevel+1 {
+		return nil, fmt.Errorf("level mapping needs %d values, got %d", MaxLevel+1, len(parts))
+	}
+	levels := make([]int, len(parts))
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || v < 0 || v > MaxLevel {
+			return nil, fmt.Errorf("bad level %q in mapping", p)
+		}
+		levels[i] = v
+	}
+	return levels, nil
+}
@@ -0,0 +1,264 @@
+package draw
+
+import (
+	"math"
+	"math/rand"
+)
+
+const MaxLevel = 4
+
+func inBounds(week, day int) bool {
+	return week >= 0 && week < Weeks && day >= 0 && day < Rows
+}
+
+// Set paints a single cell. Cells outside the graph are ignored, so shapes
+// may extend past the edges.
+func (g *Grid) Set(week, day, level int) {
+	if inBounds(week, day) {
+		g[day][week] = level
+	}
+}
+
+func (g Grid) At(week, day int) int {
+	if !inBounds(week, day) {
+		return 0
+	}
+	return g[day][week]
+}
+
+func (g *Grid) Line(w0, d0, w1, d1, level int) {
+	dw, dd := abs(w1-w0), abs(d1-d0)
+	sw, sd := 1, 1
+	if w0 > w1 {
+		sw = -1
+	}
+	if d0 > d1 {
+		sd = -1
+	}
+	err := dw - dd
+
+	for {
+		g.Set(w0, d0, level)
+		if w0 == w1 && d0 == d1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 > -dd {
+			err -= dd
+			w0 += sw
+		}
+		if e2 < dw {
+			err += dw
+			d0 += sd
+		}
+	}
+}
+
+func (g *Grid) Rect(w0, d0, w1, d1, level int, filled bool) {
+	w0, w1 = minMax(w0, w1)
+	d0, d1 = minMax(d0, d1)
+	for w := w0; w <= w1; w++ {
+		for d := d0; d <= d1; d++ {
+			if filled || w == w0 || w == w1 || d == d0 || d == d1 {
+				g.Set(w, d, level)
+			}
+		}
+	}
+}
+
+// Ellipse draws an ellipse centred on (cw, cd) with radii rw and rd.
+func (g *Grid) Ellipse(cw, cd, rw, rd, level int, filled bool) {
+	if rw <= 0 || rd <= 0 {
+		g.Line(cw-rw, cd-rd, cw+rw, cd+rd, level)
+		return
+	}
+
+	inside := func(w, d int) bool {
+		x := float64(w-cw) / (float64(rw) + 0.5)
+		y := float64(d-cd) / (float64(rd) + 0.5)
+		return x*x+y*y <= 1
+	}
+
+	for w := cw - rw; w <= cw+rw; w++ {
+		for d := cd - rd; d <= cd+rd; d++ {
+			if !inside(w, d) {
+				continue
+			}
+			edge := !inside(w-1, d) || !inside(w+1, d) || !inside(w, d-1) || !inside(w, d+1)
+			if filled || edge {
+				g.Set(w, d, level)
+			}
+		}
+	}
+}
+
+// Fill flood fills the 4-connected region of equal level around
+// (week, day).
+func (g *Grid) Fill(week, day, level int) {
+	if !inBounds(week, day) {
+		return
+	}
+	target := g[day][week]
+	if target == level {
+		return
+	}
+
+	stack := []Point{{Week: week, Day: day}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !inBounds(p.Week, p.Day) || g[p.Day][p.Week] != target {
+			continue
+		}
+		g[p.Day][p.Week] = level
+		stack = append(stack,
+			Point{Week: p.Week + 1, Day: p.Day},
+			Point{Week: p.Week - 1, Day: p.Day},
+			Point{Week: p.Week, Day: p.Day + 1},
+			Point{Week: p.Week, Day: p.Day - 1},
+		)
+	}
+}
+
+// Gradient ramps from one level to another across the weeks, or across
+// the days if vertical is set.
+func Gradient(from, to int, vertical bool) Grid {
+	var g Grid
+	steps := Weeks - 1
+	if vertical {
+		steps = Rows - 1
+	}
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			pos := w
+			if vertical {
+				pos = d
+			}
+			g[d][w] = from + int(math.Round(float64((to-from)*pos)/float64(steps)))
+		}
+	}
+	return g
+}
+
+func Checkerboard(size, a, b int) Grid {
+	var g Grid
+	if size < 1 {
+		size = 1
+	}
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			if (w/size+d/size)%2 == 0 {
+				g[d][w] = a
+			} else {
+				g[d][w] = b
+			}
+		}
+	}
+	return g
+}
+
+// Stripes alternates bands of width cells of level a and b, running down
+// the weeks or, if horizontal is set, along the days.
+func Stripes(width, a, b int, horizontal bool) Grid {
+	var g Grid
+	if width < 1 {
+		width = 1
+	}
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			pos := w
+			if horizontal {
+				pos = d
+			}
+			if (pos/width)%2 == 0 {
+				g[d][w] = a
+			} else {
+				g[d][w] = b
+			}
+		}
+	}
+	return g
+}
+
+// Noise sets each cell with probability density to a random level. The
+// same seed always gives the same grid.
+func Noise(seed int64, density float64) Grid {
+	var g Grid
+	rng := rand.New(rand.NewSource(seed))
+	for w := 0; w < Weeks; w++ {
+		for d := 0; d < Rows; d++ {
+			if rng.Float64() < density {
+				g[d][w] = 1 + rng.Intn(MaxLevel)
+			}
+		}
+	}
+	return g
+}
+
+// Activity looks like a real, busy contribution graph: quiet weekends,
+// waves of busier weeks and mostly low levels.
+func Activity(seed int64) Grid {
+	var g Grid
+	rng := rand.New(rand.NewSource(seed))
+	base := 0.4 + rng.Float64()*0.3
+
+	for w := 0; w < Weeks; w++ {
+		for d := 0; d < Rows; d++ {
+			chance := base
+			if d == 0 || d == 6 {
+				chance = 0.15
+			}
+			chance += math.Sin(float64(w)*0.5) * 0.2
+			if rng.Float64() >= chance {
+				continue
+			}
+
+			r := rng.Float64()
+			switch {
+			case r < 0.35:
+				g[d][w] = 1
+			case r < 0.6:
+				g[d][w] = 2
+			case r < 0.85:
+				g[d][w] = 3
+			default:
+				g[d][w] = 4
+			}
+		}
+	}
+	return g
+}
+
+var Patterns = []string{"gradient", "checker", "stripes", "noise", "activity"}
+
+// Pattern returns one of Patterns with default settings. Seed only
+// matters for the random ones.
+func Pattern(name string, seed int64) (Grid, bool) {
+	switch name {
+	case "gradient":
+		return Gradient(1, MaxLevel, false), true
+	case "checker":
+		return Checkerboard(1, 1, MaxLevel), true
+	case "stripes":
+		return Stripes(2, 1, 3, false), true
+	case "noise":
+		return Noise(seed, 0.5), true
+	case "activity":
+		return Activity(seed), true
+	}
+	return Grid{}, false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func minMax(a, b int) (int, int) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
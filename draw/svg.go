@@ -0,0 +1,41 @@
+package draw
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Colors are GitHub's dark theme shades for levels 0 to MaxLevel.
+var Colors = [MaxLevel + 1]string{"#161b22", "#0e4429", "#006d32", "#26a641", "#39d353"}
+
+const (
+	svgCell = 10
+	svgGap  = 3
+	svgLeft = 30
+)
+
+// SVG renders the grid the way cal's platform draws a contribution graph.
+func (g Grid) SVG(cal Calendar) string {
+	step := svgCell + svgGap
+	width := svgLeft + Weeks*step
+	height := Rows * step
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	sb.WriteString("\n")
+	for row, day := range Weekdays(cal) {
+		if day == time.Monday || day == time.Wednesday || day == time.Friday {
+			fmt.Fprintf(&sb, `<text x="0" y="%d" font-size="9" font-family="sans-serif" fill="#7d8590">%s</text>`+"\n", row*step+svgCell-1, day.String()[:3])
+		}
+	}
+	colors := cal.Colors()
+	for row := 0; row < Rows; row++ {
+		for col := 0; col < Weeks; col++ {
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"/>`+"\n",
+				svgLeft+col*step, row*step, svgCell, svgCell, colors[clampLevel(g[row][col])])
+		}
+	}
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
@@ -0,0 +1,300 @@
+package draw
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Shift moves the design dw weeks right and dd days down. With wrap set,
+// cells pushed off one edge come back on the other; otherwise they are
+// dropped.
+func (g Grid) Shift(dw, dd int, wrap bool) Grid {
+	var out Grid
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			nw, nd := w+dw, d+dd
+			if wrap {
+				nw = mod(nw, Weeks)
+				nd = mod(nd, Rows)
+			}
+			out.Set(nw, nd, g[d][w])
+		}
+	}
+	return out
+}
+
+// MirrorH flips the design left to right.
+func (g Grid) MirrorH() Grid {
+	var out Grid
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			out[d][Weeks-1-w] = g[d][w]
+		}
+	}
+	return out
+}
+
+// MirrorV flips the design top to bottom.
+func (g Grid) MirrorV() Grid {
+	var out Grid
+	for d := 0; d < Rows; d++ {
+		out[Rows-1-d] = g[d]
+	}
+	return out
+}
+
+// Rotate turns the design upside down.
+func (g Grid) Rotate() Grid {
+	return g.MirrorH().MirrorV()
+}
+
+// Turn rotates the design by quarter turns, clockwise for positive n.
+// Half turns keep the whole grid in place, like Rotate. The graph is not
+// square, so a quarter turn instead turns the painted cells about the top
+// left corner of the box around them, dropping what no longer fits.
+func (g Grid) Turn(n int) Grid {
+	w0, d0, w1, d1, _ := g.bounds()
+	return g.turn(n, w0, d0, w1, d1)
+}
+
+// turn is Turn about the given box, so layers turn together.
+func (g Grid) turn(n, w0, d0, w1, d1 int) Grid {
+	switch mod(n, 4) {
+	case 0:
+		return g
+	case 2:
+		return g.Rotate()
+	}
+	clockwise := mod(n, 4) == 1
+	var out Grid
+	for d := max(d0, 0); d <= d1; d++ {
+		for w := max(w0, 0); w <= w1; w++ {
+			nx, ny := d1-d, w-w0
+			if !clockwise {
+				nx, ny = d-d0, w1-w
+			}
+			out.Set(w0+nx, d0+ny, g[d][w])
+		}
+	}
+	return out
+}
+
+// bounds is the smallest rectangle holding every painted cell, or false
+// if the grid is empty.
+func (g Grid) bounds() (w0, d0, w1, d1 int, ok bool) {
+	w0, d0, w1, d1 = Weeks, Rows, -1, -1
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			if g[d][w] == 0 {
+				continue
+			}
+			w0, w1 = min(w0, w), max(w1, w)
+			d0, d1 = min(d0, d), max(d1, d)
+		}
+	}
+	return w0, d0, w1, d1, w1 >= 0
+}
+
+// Invert swaps empty and full cells, level n becoming MaxLevel-n.
+func (g Grid) Invert() Grid {
+	var out Grid
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			out[d][w] = MaxLevel - clampLevel(g[d][w])
+		}
+	}
+	return out
+}
+
+// Scale multiplies every level by factor, rounding and clamping to the
+// valid range. Painted cells never scale down to empty.
+func (g Grid) Scale(factor float64) Grid {
+	var out Grid
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			if g[d][w] == 0 {
+				continue
+			}
+			level := clampLevel(int(math.Round(float64(g[d][w]) * factor)))
+			if level == 0 {
+				level = 1
+			}
+			out[d][w] = level
+		}
+	}
+	return out
+}
+
+// Crop returns the rectangle between the two corners, moved to the top
+// left of an otherwise empty grid.
+func (g Grid) Crop(w0, d0, w1, d1 int) Grid {
+	w0, w1 = minMax(w0, w1)
+	d0, d1 = minMax(d0, d1)
+	var out Grid
+	for d := d0; d <= d1; d++ {
+		for w := w0; w <= w1; w++ {
+			out.Set(w-w0, d-d0, g.At(w, d))
+		}
+	}
+	return out
+}
+
+// Paste draws every painted cell of src onto g, offset by week and day.
+func (g *Grid) Paste(src Grid, week, day int) {
+	for d := 0; d < Rows; d++ {
+		for w := 0; w < Weeks; w++ {
+			if src[d][w] != 0 {
+				g.Set(w+week, d+day, src[d][w])
+			}
+		}
+	}
+}
+
+// Transform applies a comma separated list of operations, in order:
+//
+//	shift:W[:D]              move W weeks right and D days down, clipping
+//	scroll:W[:D]             the same, wrapping around the edges
+//	mirror-h                 flip left to right
+//	mirror-v                 flip top to bottom
+//	rotate[:DEG]             turn by 90, 180 (the default) or 270 degrees
+//	invert                   swap empty and full
+//	scale:F                  multiply levels by F
+//	crop:W0:D0:W1:D1         keep only the rectangle, moved to the top left
+//	copy:W0:D0:W1:D1:W:D     paste a copy of the rectangle at week W, day D
+func Transform(g Grid, ops string) (Grid, error) {
+	layers := []Layer{{Grid: g}}
+	err := transform(layers, ops)
+	return layers[0].Grid, err
+}
+
+// TransformLayers applies Transform operations to every layer, turning
+// them about the box around all of them so they stay lined up. Invert
+// works on what the layers make together: the flattened design, inverted,
+// becomes the bottom layer and the others are left empty.
+func TransformLayers(layers []Layer, ops string) error {
+	work := slices.Clone(layers)
+	if err := transform(work, ops); err != nil {
+		return err
+	}
+	copy(layers, work)
+	return nil
+}
+
+func transform(layers []Layer, ops string) error {
+	for _, op := range strings.Split(ops, ",") {
+		op = strings.TrimSpace(op)
+		if op == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(op, ":")
+
+		switch name {
+		case "shift", "scroll":
+			n, err := ints(arg, 1, 2)
+			if err != nil {
+				return fmt.Errorf("%s needs W[:D] offsets: %s", name, op)
+			}
+			n = append(n, 0)
+			each(layers, func(g Grid) Grid { return g.Shift(n[0], n[1], name == "scroll") })
+		case "mirror-h":
+			each(layers, Grid.MirrorH)
+		case "mirror-v":
+			each(layers, Grid.MirrorV)
+		case "rotate":
+			deg := 180
+			if arg != "" {
+				var err error
+				if deg, err = strconv.Atoi(arg); err != nil || deg%90 != 0 {
+					return fmt.Errorf("rotate needs a multiple of 90 degrees: %s", op)
+				}
+			}
+			w0, d0, w1, d1, _ := union(layers)
+			each(layers, func(g Grid) Grid { return g.turn(deg/90, w0, d0, w1, d1) })
+		case "invert":
+			// each layer inverted on its own would not add up to the
+			// design inverted
+			inverted := Flatten(layers...).Invert()
+			for i := range layers {
+				layers[i].Grid = Grid{}
+			}
+			layers[0] = Layer{Name: layers[0].Name, Grid: inverted}
+		case "scale":
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil || !(f > 0) || math.IsInf(f, 1) {
+				return fmt.Errorf("scale needs a positive factor: %s", op)
+			}
+			each(layers, func(g Grid) Grid { return g.Scale(f) })
+		case "crop":
+			n, err := ints(arg, 4, 4)
+			if err != nil {
+				return fmt.Errorf("crop needs W0:D0:W1:D1: %s", op)
+			}
+			each(layers, func(g Grid) Grid { return g.Crop(n[0], n[1], n[2], n[3]) })
+		case "copy":
+			n, err := ints(arg, 6, 6)
+			if err != nil {
+				return fmt.Errorf("copy needs W0:D0:W1:D1:W:D: %s", op)
+			}
+			each(layers, func(g Grid) Grid {
+				g.Paste(g.Crop(n[0], n[1], n[2], n[3]), n[4], n[5])
+				return g
+			})
+		default:
+			return fmt.Errorf("unknown transform: %s", name)
+		}
+	}
+	return nil
+}
+
+func each(layers []Layer, f func(Grid) Grid) {
+	for i := range layers {
+		layers[i].Grid = f(layers[i].Grid)
+	}
+}
+
+// union is the box around the painted cells of every layer.
+func union(layers []Layer) (w0, d0, w1, d1 int, ok bool) {
+	w0, d0, w1, d1 = Weeks, Rows, -1, -1
+	for _, l := range layers {
+		if a, b, c, d, painted := l.Grid.bounds(); painted {
+			w0, d0, w1, d1, ok = min(w0, a), min(d0, b), max(w1, c), max(d1, d), true
+		}
+	}
+	return w0, d0, w1, d1, ok
+}
+
+// ints parses between least and most colon separated integers.
+func ints(arg string, least, most int) ([]int, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("no arguments")
+	}
+	parts := strings.Split(arg, ":")
+	if len(parts) < least || len(parts) > most {
+		return nil, fmt.Errorf("want %d to %d arguments, got %d", least, most, len(parts))
+	}
+	n := make([]int, len(parts))
+	for i, p := range parts {
+		var err error
+		if n[i], err = strconv.Atoi(p); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func clampLevel(level int) int {
+	if level < 0 {
+		return 0
+	}
+	if level > MaxLevel {
+		return MaxLevel
+	}
+	return level
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/1etu/gitdraw/draw"
+	"golang.org/x/term"
+)
+
+// palette holds the GitHub dark theme greens, from empty to level 4.
+var palette = [draw.MaxLevel + 1]string{
+	"\033[48;2;22;27;34m",
+	"\033[48;2;14;68;41m",
+	"\033[48;2;0;109;50m",
+	"\033[48;2;38;166;65m",
+	"\033[48;2;57;211;83m",
+}
+
+// shades stand in for the palette when colours are off.
+var shades = [draw.MaxLevel + 1]string{" ·", "░░", "▒▒", "▓▓", "██"}
+
+var tools = []string{"brush", "line", "rect", "fill"}
+
+const editHelp = "hjkl/arrows move  0-4 level  space paint  x erase  p pen  tab tool  c clear\r\n" +
+	"  u undo  ^R redo  s save  o open  g generate  q quit"
+
+type editor struct {
+	grid      draw.Grid
+	cal       draw.Calendar
+	week, day int
+	level     int
+	tool      int
+	pen       bool
+	anchor    *draw.Point
+	undo      []draw.Grid
+	redo      []draw.Grid
+	file      string
+	dirty     bool
+	status    string
+}
+
+// runEdit is `gitdraw edit [file] [options]`: a full screen editor for
+// points JSON files that can generate the result like the CLI does.
+func runEdit(args []string) {
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	opts, err := parseRunFlags(args)
+	if err == nil {
+		err = setupOutput(opts.output)
+		unattended = opts.yes
+	}
+	if err != nil {
+		exit(err.Error())
+	}
+	defer finish()
+
+	if !interactive || !isTerminal(os.Stdin) {
+		exit("edit needs a terminal")
+	}
+
+	e := &editor{level: draw.MaxLevel, file: file, cal: opts.calendar}
+	if file != "" {
+		if err := e.load(file); err != nil && !os.IsNotExist(err) {
+			exit(err.Error())
+		}
+	}
+
+	restore, err := rawMode()
+	if err != nil {
+		exit("could not switch the terminal to raw mode: " + err.Error())
+	}
+	fmt.Fprint(console, "\033[?1049h\033[?25l")
+	generateNow := e.loop()
+	fmt.Fprint(console, "\033[?25h\033[?1049l")
+	restore()
+
+	if !generateNow {
+		result.Error = "cancelled"
+		return
+	}
+
+	printHeader()
+	printPreview(opts.calendar, e.grid)
+	generate(opts, e.grid, nil)
+}
+
+// loop handles keys until the user quits, or asks to generate, in which
+// case it returns true.
+func (e *editor) loop() bool {
+	quitting := false
+	for {
+		e.render()
+		key := readKey()
+		e.status = ""
+
+		switch key {
+		case "q", "ctrl-c":
+			if e.dirty && !quitting && key == "q" {
+				e.status = "unsaved changes, press q again to quit"
+				quitting = true
+				continue
+			}
+			return false
+		case "g":
+			if e.grid == (draw.Grid{}) {
+				e.status = "nothing to generate"
+				continue
+			}
+			return true
+		case "h", "left":
+			e.move(-1, 0)
+		case "l", "right":
+			e.move(1, 0)
+		case "k", "up":
+			e.move(0, -1)
+		case "j", "down":
+			e.move(0, 1)
+		case "0", "1", "2", "3", "4":
+			e.level = int(key[0] - '0')
+		case " ", "enter":
+			e.act()
+		case "x":
+			e.edit(func(g *draw.Grid) { g.Set(e.week, e.day, 0) })
+		case "p":
+			e.pen = !e.pen
+			if e.pen {
+				e.tool = 0
+				e.edit(func(g *draw.Grid) { g.Set(e.week, e.day, e.level) })
+			}
+		case "tab":
+			e.tool = (e.tool + 1) % len(tools)
+			e.anchor, e.pen = nil, false
+		case "esc":
+			e.anchor, e.pen = nil, false
+		case "c":
+			e.edit(func(g *draw.Grid) { *g = draw.Grid{} })
+		case "u":
+			e.undoRedo(&e.undo, &e.redo)
+		case "U", "ctrl-r":
+			e.undoRedo(&e.redo, &e.undo)
+		case "s":
+			if name := e.prompt("Save to", e.file); name != "" {
+				if err := e.save(name); err != nil {
+					e.status = "save failed: " + err.Error()
+				} else {
+					e.status = "saved " + name
+				}
+			}
+		case "o":
+			if name := e.prompt("Open", e.file); name != "" {
+				if err := e.load(name); err != nil {
+					e.status = "open failed: " + err.Error()
+				} else {
+					e.status = "opened " + name
+				}
+			}
+		}
+		if key != "q" {
+			quitting = false
+		}
+	}
+}
+
+func (e *editor) move(dw, dd int) {
+	e.week = min(max(e.week+dw, 0), draw.Weeks-1)
+	e.day = min(max(e.day+dd, 0), draw.Rows-1)
+	if e.pen {
+		e.edit(func(g *draw.Grid) { g.Set(e.week, e.day, e.level) })
+	}
+}
+
+func (e *editor) act() {
+	switch tools[e.tool] {
+	case "brush":
+		e.edit(func(g *draw.Grid) { g.Set(e.week, e.day, e.level) })
+	case "fill":
+		e.edit(func(g *draw.Grid) { g.Fill(e.week, e.day, e.level) })
+	default:
+		if e.anchor == nil {
+			e.anchor = &draw.Point{Week: e.week, Day: e.day}
+			e.status = "move to the other end and press space"
+			return
+		}
+		a := *e.anchor
+		e.anchor = nil
+		e.edit(func(g *draw.Grid) {
+			if tools[e.tool] == "line" {
+				g.Line(a.Week, a.Day, e.week, e.day, e.level)
+			} else {
+				g.Rect(a.Week, a.Day, e.week, e.day, e.level, false)
+			}
+		})
+	}
+}
+
+// edit applies fn to the grid, recording an undo step if anything
+// changed.
+func (e *editor) edit(fn func(g *draw.Grid)) {
+	before := e.grid
+	fn(&e.grid)
+	if e.grid == before {
+		return
+	}
+	e.undo = append(e.undo, before)
+	if len(e.undo) > 200 {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+	e.dirty = true
+}
+
+// undoRedo moves one step from stack `from` back onto the grid, saving
+// the current grid on `to`.
+func (e *editor) undoRedo(from, to *[]draw.Grid) {
+	if len(*from) == 0 {
+		return
+	}
+	*to = append(*to, e.grid)
+	e.grid = (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	e.dirty = true
+}
+
+func (e *editor) load(name string) error {
+	grid, err := readPoints(name)
+	if err != nil {
+		return err
+	}
+	// a new file starts a new history; undo does not go back to the last
+	e.grid, e.undo, e.redo = grid, nil, nil
+	e.file, e.dirty = name, false
+	return nil
+}
+
+func (e *editor) save(name string) error {
+	if err := os.WriteFile(name, []byte(gridToPoints(e.grid)+"\n"), 0644); err != nil {
+		return err
+	}
+	e.file, e.dirty = name, false
+	return nil
+}
+
+func (e *editor) render() {
+	var sb strings.Builder
+	sb.WriteString("\033[H\033[2J\r\n")
+	sb.WriteString("  " + bold + "gitdraw edit" + reset + dim + " — " + e.title() + reset + "\r\n\r\n")
+
+	var days [draw.Rows]string
+	for row, day := range draw.Weekdays(e.cal) {
+		days[row] = day.String()[:3]
+	}
+	for d := 0; d < draw.Rows; d++ {
+		sb.WriteString("  " + dim + days[d] + reset + " ")
+		for w := 0; w < draw.Weeks; w++ {
+			sb.WriteString(e.cell(w, d))
+		}
+		sb.WriteString(reset + "\r\n")
+	}
+
+	pen := ""
+	if e.pen {
+		pen = " (pen down)"
+	}
+	sb.WriteString(fmt.Sprintf("\r\n  tool %s%s  level %s%d%s  week %d %s\r\n",
+		bold+tools[e.tool]+reset, pen, bold, e.level, reset, e.week, days[e.day]))
+	sb.WriteString("  " + e.status + "\r\n\r\n")
+	sb.WriteString("  " + dim + editHelp + reset + "\r\n")
+	fmt.Fprint(console, sb.String())
+}
+
+func (e *editor) title() string {
+	name := e.file
+	if name == "" {
+		name = "untitled"
+	}
+	if e.dirty {
+		name += " *"
+	}
+	return name
+}
+
+func (e *editor) cell(w, d int) string {
+	level := e.grid.At(w, d)
+	marked := e.anchor != nil && e.anchor.Week == w && e.anchor.Day == d
+	cursor := w == e.week && d == e.day
+
+	if !color {
+		switch {
+		case cursor:
+			return "[]"
+		case marked:
+			return "<>"
+		}
+		return shades[level]
+	}
+	switch {
+	case cursor:
+		return palette[level] + "\033[97m[]" + reset
+	case marked:
+		return palette[level] + "\033[97m<>" + reset
+	}
+	return palette[level] + "  " + reset
+}
+
+// prompt reads a line on the status line, returning "" if cancelled with
+// escape.
+func (e *editor) prompt(label, def string) string {
+	line := def
+	for {
+		e.status = label + ": " + line + "_"
+		e.render()
+		switch key := readKey(); key {
+		case "enter":
+			return strings.TrimSpace(line)
+		case "esc", "ctrl-c":
+			return ""
+		case "backspace":
+			if line != "" {
+				line = line[:len(line)-1]
+			}
+		default:
+			if len(key) == 1 {
+				line += key
+			}
+		}
+	}
+}
+
+// readKey reads one key press from the raw terminal and names the special
+// ones.
+func readKey() string {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return "ctrl-c"
+	}
+	switch b {
+	case 3:
+		return "ctrl-c"
+	case 9:
+		return "tab"
+	case 13, 10:
+		return "enter"
+	case 18:
+		return "ctrl-r"
+	case 8, 127:
+		return "backspace"
+	case 27:
+		if reader.Buffered() < 2 {
+			return "esc"
+		}
+		reader.ReadByte()
+		switch c, _ := reader.ReadByte(); c {
+		case 'A':
+			return "up"
+		case 'B':
+			return "down"
+		case 'C':
+			return "right"
+		case 'D':
+			return "left"
+		}
+		return ""
+	}
+	return string(b)
+}
+
+// rawMode puts the terminal into raw mode and returns a function that
+// restores the previous settings.
+func rawMode() (func(), error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() { term.Restore(fd, state) }, nil
+}
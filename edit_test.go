@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+// keys feeds the editor the given input in place of the terminal.
+func keys(t *testing.T, input string) {
+	old, oldConsole := reader, console
+	reader, console = bufio.NewReader(strings.NewReader(input)), io.Discard
+	t.Cleanup(func() { reader, console = old, oldConsole })
+}
+
+func TestEditorTools(t *testing.T) {
+	e := &editor{level: 3, cal: draw.GitHub{}}
+
+	e.week, e.day = 2, 1
+	e.act()
+	if e.grid.At(2, 1) != 3 || !e.dirty || len(e.undo) != 1 {
+		t.Fatalf("brush: level %d, dirty %v, %d undo steps", e.grid.At(2, 1), e.dirty, len(e.undo))
+	}
+	// painting the same level again changes nothing, so is not a step
+	e.act()
+	if len(e.undo) != 1 {
+		t.Errorf("a no-op brush recorded %d undo steps", len(e.undo))
+	}
+
+	e.tool = 1 // line
+	e.week, e.day = 5, 0
+	e.act()
+	if e.anchor == nil || len(e.undo) != 1 {
+		t.Fatal("the first press of the line tool did not just set the anchor")
+	}
+	e.week = 9
+	e.act()
+	if e.anchor != nil || e.grid.At(7, 0) != 3 {
+		t.Error("the second press of the line tool did not draw the line")
+	}
+
+	e.tool = 3 // fill
+	e.level = 1
+	e.week, e.day = 20, 5
+	e.act()
+	if e.grid.At(40, 6) != 1 || e.grid.At(2, 1) != 3 {
+		t.Error("fill did not fill the empty cells around the cursor only")
+	}
+}
+
+func TestEditorUndoRedo(t *testing.T) {
+	e := &editor{level: 4}
+	e.act()
+	e.move(1, 0)
+	e.act()
+	painted := e.grid
+
+	e.undoRedo(&e.undo, &e.redo)
+	if e.grid.At(1, 0) != 0 || e.grid.At(0, 0) != 4 {
+		t.Error("undo did not take back the last stroke only")
+	}
+	e.undoRedo(&e.redo, &e.undo)
+	if e.grid != painted {
+		t.Error("redo did not put the stroke back")
+	}
+	e.undoRedo(&e.redo, &e.undo)
+	if e.grid != painted {
+		t.Error("redo with nothing to redo changed the grid")
+	}
+
+	e.undoRedo(&e.undo, &e.redo)
+	e.edit(func(g *draw.Grid) { g.Set(9, 2, 2) })
+	if len(e.redo) != 0 {
+		t.Error("a new edit kept the redo steps")
+	}
+
+	// opening a file starts over: nothing to undo back into the old grid
+	name := filepath.Join(t.TempDir(), "points.json")
+	if err := e.save(name); err != nil {
+		t.Fatal(err)
+	}
+	e.undoRedo(&e.undo, &e.redo)
+	if err := e.load(name); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.undo) != 0 || len(e.redo) != 0 || e.grid.At(9, 2) != 2 || e.dirty {
+		t.Errorf("load kept %d undo and %d redo steps", len(e.undo), len(e.redo))
+	}
+}
+
+func TestEditorLoop(t *testing.T) {
+	// paint a pen stroke to the right, undo its last cell, then generate
+	keys(t, "p\x1b[C\x1b[C\x1b[Cug")
+	e := &editor{level: 2, cal: draw.GitHub{}}
+	if !e.loop() {
+		t.Fatal("g did not ask to generate")
+	}
+	for w, want := range []int{2, 2, 2, 0} {
+		if got := e.grid.At(w, 0); got != want {
+			t.Errorf("week %d = %d, want %d", w, got, want)
+		}
+	}
+
+	// q with unsaved changes asks again; a second q quits
+	keys(t, " qqg")
+	e = &editor{level: 2, cal: draw.GitHub{}}
+	if e.loop() || reader.Buffered() != 1 {
+		t.Error("the second q did not quit")
+	}
+
+	keys(t, "g")
+	e = &editor{cal: draw.GitHub{}}
+	if e.loop() {
+		t.Error("generated an empty grid")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/1etu/gitdraw/git"
+)
+
+func runErase(args []string) {
+	setupOutput(outputText)
+
+	fs := flag.NewFlagSet("erase", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dryRun := fs.Bool("dry-run", false, "list commits without changing anything")
+	branch := fs.String("branch", "", "branch to clean (default: current)")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+
+	if err := fs.Parse(args); err != nil {
+		exit(err.Error())
+	}
+	if fs.NArg() != 1 {
+		exit("usage: gitdraw erase [--dry-run] [--branch <name>] [--yes] <repo>")
+	}
+
+	repo := &git.Repo{Path: fs.Arg(0)}
+	if *branch == "" {
+		current, err := repo.CurrentBranch()
+		if err != nil {
+			exit(err.Error())
+		}
+		*branch = current
+	}
+
+	plan, err := repo.PlanErase(*branch)
+	if err != nil {
+		exit(err.Error())
+	}
+
+	fmt.Fprintln(console)
+	if len(plan.Drawn) == 0 {
+		info("gitdraw commits", "none on "+*branch)
+		fmt.Fprintln(console)
+		return
+	}
+
+	info("branch", *branch)
+	info("gitdraw commits", fmt.Sprintf("%d", len(plan.Drawn)))
+	if d, err := repo.ReadDesign(plan.Drawn[len(plan.Drawn)-1].Hash); err != nil {
+		warn(err.Error())
+	} else if d != nil {
+		info("design", fmt.Sprintf("%s (year %d, intensity %d)", d.Hash(), d.Year, d.Intensity))
+	}
+	if *dryRun {
+		fmt.Fprintln(console)
+		for _, e := range plan.Drawn {
+			fmt.Fprintf(console, "  %s%s%s %s %s\n", dim, e.Hash[:12], reset, e.Date.Format("2006-01-02"), e.Subject)
+		}
+	}
+
+	switch plan.Mode {
+	case git.EraseReset:
+		info("action", "reset to "+plan.Base[:12])
+	case git.EraseRewrite:
+		info("action", fmt.Sprintf("rewrite, replaying %d other commits", len(plan.Replay)))
+	case git.EraseAll:
+		info("action", "none, the whole branch is a drawing (delete the repository instead)")
+	}
+	fmt.Fprintln(console)
+
+	if *dryRun || plan.Mode == git.EraseAll {
+		return
+	}
+	if !*yes && !confirm("Remove these commits") {
+		fmt.Fprintln(console, dim+"Cancelled."+reset)
+		return
+	}
+
+	if err := repo.Erase(plan); err != nil {
+		exit(err.Error())
+	}
+
+	fmt.Fprintln(console)
+	success("Drawing erased")
+	info("previous tip", git.BackupRef)
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, dim+"  Push with --force-with-lease to update the remote."+reset)
+	fmt.Fprintln(console)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/config"
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/font"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/levels"
+	"github.com/1etu/gitdraw/shard"
+	"github.com/1etu/gitdraw/snapshot"
+)
+
+type runOptions struct {
+	style      git.Style
+	push       git.PushOptions
+	force      bool
+	pattern    string
+	seed       int64
+	transform  string
+	cfg        config.Config
+	output     string
+	minimum    bool
+	levels     []int
+	blend      draw.Blend
+	layers     []layerSpec
+	calendar   draw.Calendar
+	existing   *snapshot.Snapshot
+	activity   levels.Counts
+	previewSVG string
+	shardBy    shard.Strategy
+	shards     int
+	yes        bool
+}
+
+// parseRunFlags parses the options of the interactive CLI. Subcommands
+// that take them define their own flags as well with more.
+func parseRunFlags(args []string, more ...func(fs *flag.FlagSet)) (runOptions, error) {
+	var opts runOptions
+	var files, force, levels, blend, configPath, author, existing, activity, shardBy string
+	var flags config.Config
+
+	fs := flag.NewFlagSet("gitdraw", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.style.Message, "message", git.DefaultMessage, "commit message template")
+	fs.StringVar(&opts.style.Content, "content", git.ContentOverwrite, "content strategy")
+	fs.StringVar(&opts.style.Entry, "entry", git.DefaultEntry, "journal line template")
+	fs.StringVar(&files, "files", "", "comma separated file path templates")
+	fs.StringVar(&opts.push.Remote, "remote-name", git.DefaultRemote, "remote to push to")
+	fs.StringVar(&opts.push.Branch, "branch", git.Branch, "remote branch to push to")
+	fs.StringVar(&opts.output, "output", outputText, "text or json")
+	fs.StringVar(&configPath, "config", "", "config file, instead of ./"+config.FileName+" or the user config")
+	fs.StringVar(&flags.Text, "text", "", "default text to draw, may use {year}, {yy}, {user} and {date}")
+	fs.IntVar(&flags.Year, "year", 0, "default target year")
+	fs.IntVar(&flags.Intensity, "intensity", 0, "default text intensity")
+	fs.BoolFunc("fill", "fill the background without asking", func(s string) error {
+		fill, err := strconv.ParseBool(s)
+		flags.Fill = &fill
+		return err
+	})
+	fs.StringVar(&author, "author", "", `commit author, "Name" or "Name <email>"`)
+	fs.StringVar(&flags.Remote, "remote", "", "default remote URL")
+	fs.StringVar(&flags.Font, "font", "", "font to draw text with")
+	fs.StringVar(&flags.Timezone, "timezone", "", "timezone to date commits in, e.g. Europe/Berlin")
+	fs.StringVar(&flags.Output, "dir", "", "default output directory")
+	fs.StringVar(&flags.Calendar, "calendar", "", "platform calendar to draw on: "+strings.Join(draw.Calendars, ", "))
+	fs.StringVar(&flags.WeekStart, "week-start", "", "weekday of the top row, e.g. monday")
+	fs.StringVar(&existing, "existing", "", "saved contribution calendar to draw on top of")
+	fs.StringVar(&activity, "activity", "", "comma separated local repos whose commits are already on the graph")
+	fs.StringVar(&opts.previewSVG, "preview-svg", "", "write an SVG of how the graph will look")
+	fs.StringVar(&opts.pattern, "pattern", "", "start from a pattern: "+strings.Join(draw.Patterns, ", "))
+	fs.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "seed for the noise and activity patterns")
+	fs.StringVar(&levels, "pattern-levels", "", "level mapping for the pattern layer, e.g. 0,1,1,2,2")
+	fs.StringVar(&blend, "blend", string(draw.BlendReplace), "how text is blended over the pattern: max, add or replace")
+	fs.Func("layer", "a layer of the design, bottom first: text, a pattern or a points file, with :blend and :levels", func(s string) error {
+		l, err := parseLayer(s)
+		opts.layers = append(opts.layers, l)
+		return err
+	})
+	fs.StringVar(&opts.transform, "transform", "", "comma separated grid transforms")
+	fs.BoolVar(&opts.minimum, "min-commits", false, "use the fewest commits that keep the shading")
+	fs.BoolVar(&opts.yes, "yes", false, "answer every question with its default")
+	fs.BoolVar(&opts.force, "force", false, "allow deleting an existing output directory")
+	fs.StringVar(&force, "push-mode", "none", "none, lease or force")
+	fs.StringVar(&shardBy, "shard", "", "split the commits across repositories: "+strings.Join(shard.Strategies, ", "))
+	fs.IntVar(&opts.shards, "shards", 2, "number of repositories for --shard round-robin")
+	for _, f := range more {
+		f(fs)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+	if author != "" {
+		if err := flags.Set("author", author); err != nil {
+			return opts, err
+		}
+	}
+	if flags.Timezone != "" {
+		if err := flags.Set("timezone", flags.Timezone); err != nil {
+			return opts, err
+		}
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return opts, err
+	}
+	opts.cfg = cfg.Override(flags)
+	if opts.cfg.Font != "" && opts.cfg.Font != font.Default {
+		return opts, fmt.Errorf("unknown font: %s", opts.cfg.Font)
+	}
+	if opts.calendar, err = draw.ParseCalendar(opts.cfg.Calendar); err != nil {
+		return opts, err
+	}
+	if opts.cfg.WeekStart != "" {
+		first, err := draw.ParseWeekday(opts.cfg.WeekStart)
+		if err != nil {
+			return opts, err
+		}
+		opts.calendar = draw.StartWeek(opts.calendar, first)
+	}
+	if existing != "" {
+		if opts.existing, err = snapshot.Read(existing); err != nil {
+			return opts, err
+		}
+	}
+	if activity != "" {
+		if opts.activity, err = git.Activity(strings.Split(activity, ","), authors(opts.cfg.Email)); err != nil {
+			return opts, err
+		}
+	}
+	if !git.ValidContent(opts.style.Content) {
+		return opts, fmt.Errorf("unknown content strategy: %s", opts.style.Content)
+	}
+	if opts.push.Force, err = git.ParseForce(force); err != nil {
+		return opts, err
+	}
+	if opts.shardBy, err = shard.ParseStrategy(shardBy); err != nil {
+		return opts, err
+	}
+	if opts.shardBy == shard.ByYear && !draw.Rolling(opts.calendar) {
+		return opts, fmt.Errorf("--shard year has nothing to split: %s shows a single year", opts.calendar.Name())
+	}
+	if opts.layers != nil && (opts.pattern != "" || levels != "") {
+		return opts, fmt.Errorf("--pattern and --pattern-levels cannot be used with --layer, give the pattern its own --layer")
+	}
+	if _, ok := draw.Pattern(opts.pattern, 0); opts.pattern != "" && !ok {
+		return opts, fmt.Errorf("unknown pattern: %s", opts.pattern)
+	}
+	if opts.levels, err = draw.ParseLevels(levels); err != nil {
+		return opts, err
+	}
+	if opts.blend, err = draw.ParseBlend(blend); err != nil {
+		return opts, err
+	}
+	if files != "" {
+		opts.style.Files = strings.Split(files, ",")
+	}
+	return opts, nil
+}
+
+// authors are the emails whose commits count as the user's: the
+// configured one, or git's.
+func authors(configured string) []string {
+	email := configured
+	if email == "" {
+		_, email = getGitUser()
+	}
+	if email == "" {
+		return nil
+	}
+	return []string{email}
+}
+
+// existingCounts are the contributions already on the graph, or nil if
+// neither a saved calendar nor local activity was given.
+func (o runOptions) existingCounts() levels.Counts {
+	return existingCounts(o.existing, o.activity)
+}
+
+// existingCounts merges a saved calendar and the activity of local
+// repositories, either of which may be missing.
+func existingCounts(s *snapshot.Snapshot, activity levels.Counts) levels.Counts {
+	if s == nil && activity == nil {
+		return nil
+	}
+	counts := levels.Counts{}
+	if s != nil {
+		counts.Merge(s.Counts())
+	}
+	counts.Merge(activity)
+	return counts
+}
+
+// layerSpec is a --layer: the layer's settings and where its cells come
+// from, "text", a pattern name or, already read, a points file.
+type layerSpec struct {
+	draw.Layer
+	source string
+}
+
+// parseLayer reads SOURCE[:BLEND[:LEVELS]], e.g. noise:max:0,1,1,1,2 or
+// logo.json. A file's layers are flattened into one.
+func parseLayer(s string) (layerSpec, error) {
+	// peel the settings off the end, so a path may have colons of its own
+	parts := strings.Split(s, ":")
+	var l layerSpec
+	if n := len(parts); n > 1 && strings.Contains(parts[n-1], ",") {
+		levels, err := draw.ParseLevels(parts[n-1])
+		if err != nil {
+			return l, fmt.Errorf("layer %s: %v", s, err)
+		}
+		l.Levels, parts = levels, parts[:n-1]
+	}
+	if n := len(parts); n > 1 {
+		if b, err := draw.ParseBlend(parts[n-1]); err == nil && parts[n-1] != "" {
+			l.Blend, parts = b, parts[:n-1]
+		}
+	}
+	l.source = strings.Join(parts, ":")
+	if l.Blend == "" {
+		l.Blend = draw.BlendMax
+	}
+
+	if _, ok := draw.Pattern(l.source, 0); ok || l.source == "text" {
+		l.Name = l.source
+		return l, nil
+	}
+	grid, err := readPoints(l.source)
+	if err != nil {
+		return l, fmt.Errorf("layer %s: not text, a pattern or a points file: %v", s, err)
+	}
+	l.Name = strings.TrimSuffix(filepath.Base(l.source), filepath.Ext(l.source))
+	l.Grid = grid
+	return l, nil
+}
+
+// drawsText reports whether the design has a text layer.
+func (o runOptions) drawsText() bool {
+	if o.layers == nil {
+		return true
+	}
+	for _, l := range o.layers {
+		if l.source == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+// year is the target year from --year or the config, this year if unset.
+func (o runOptions) year() int {
+	if o.cfg.Year != 0 {
+		return o.cfg.Year
+	}
+	return time.Now().Year()
+}
+
+// design composites the layers and applies the transforms. It returns the
+// layers as well, moved the same way, for --shard layer. Without --layer
+// the layers are --pattern, if any, and the text.
+func (o runOptions) design(text string) (draw.Grid, []draw.Layer, error) {
+	specs := o.layers
+	if specs == nil {
+		if o.pattern != "" {
+			specs = append(specs, layerSpec{Layer: draw.Layer{Name: "pattern", Levels: o.levels}, source: o.pattern})
+		}
+		specs = append(specs, layerSpec{Layer: draw.Layer{Name: "text", Blend: o.blend}, source: "text"})
+	}
+	layers := make([]draw.Layer, len(specs))
+	for i, l := range specs {
+		layers[i] = l.Layer
+		if l.source == "text" {
+			layers[i].Grid = draw.Text(strings.ToUpper(expandText(text, o.year(), time.Now())))
+		} else if grid, ok := draw.Pattern(l.source, o.seed); ok {
+			layers[i].Grid = grid
+		}
+	}
+	grid, err := draw.Transform(draw.Flatten(layers...), o.transform)
+	if err != nil {
+		return grid, nil, err
+	}
+	return grid, layers, draw.TransformLayers(layers, o.transform)
+}
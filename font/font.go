@@ -1,5 +1,8 @@
 package font
 
+// Default names the built-in 5x7 font, currently the only one.
+const Default = "default"
+
 type Glyph [7]byte
 
 var Glyphs = map[rune]Glyph{
@@ -0,0 +1,178 @@
+package font
+
+import (
+	"sort"
+	"strings"
+)
+
+// Sprite is a pixel icon as tall as the graph. Each string is a row: '.'
+// is an empty cell, the digits 1 to 4 a level, and '#' the darkest level.
+type Sprite [7]string
+
+var Sprites = map[string]Sprite{
+	"heart": {
+		".##.##.",
+		"#######",
+		"#######",
+		".#####.",
+		"..###..",
+		"...#...",
+		".......",
+	},
+	"star": {
+		"...#...",
+		"...#...",
+		"#######",
+		".#####.",
+		"..###..",
+		".##.##.",
+		"##...##",
+	},
+	"smiley": {
+		"..222..",
+		".22222.",
+		"2242422",
+		"2222222",
+		"2422242",
+		".24442.",
+		"..222..",
+	},
+	"gopher": {
+		".3.....3.",
+		"333333333",
+		"311333113",
+		"314333413",
+		"333343333",
+		".3331333.",
+		".3333333.",
+	},
+	"rocket": {
+		"...3.....",
+		"...33....",
+		"23333333.",
+		"213313333",
+		"23333333.",
+		"...33....",
+		"...3.....",
+	},
+	"arrow-right": {
+		"...#...",
+		"...##..",
+		"...###.",
+		"#######",
+		"...###.",
+		"...##..",
+		"...#...",
+	},
+	"arrow-left": {
+		"...#...",
+		"..##...",
+		".###...",
+		"#######",
+		".###...",
+		"..##...",
+		"...#...",
+	},
+	"arrow-up": {
+		"...#...",
+		"..###..",
+		".#####.",
+		"#######",
+		"..###..",
+		"..###..",
+		"..###..",
+	},
+	"arrow-down": {
+		"..###..",
+		"..###..",
+		"..###..",
+		"#######",
+		".#####.",
+		"..###..",
+		"...#...",
+	},
+	"go": {
+		".............",
+		"....###..###.",
+		"...#....#...#",
+		"##.#.##.#...#",
+		"...#..#.#...#",
+		"....###..###.",
+		".............",
+	},
+	"js": {
+		"111111111",
+		"111141444",
+		"111141411",
+		"111141444",
+		"111141114",
+		"111441444",
+		"111111111",
+	},
+	"ts": {
+		"111111111",
+		"114441444",
+		"111411411",
+		"111411444",
+		"111411114",
+		"111411444",
+		"111111111",
+	},
+	"python": {
+		".2222..",
+		".2.22..",
+		"22222.4",
+		"22...44",
+		"2.44444",
+		"..44.4.",
+		"..4444.",
+	},
+	"rust": {
+		"2.222.2",
+		".244.2.",
+		"224.422",
+		".244.2.",
+		"224.422",
+		".24.42.",
+		"2.222.2",
+	},
+}
+
+// Width is the number of columns the sprite takes.
+func (s Sprite) Width() int {
+	w := 0
+	for _, row := range s {
+		w = max(w, len(row))
+	}
+	return w
+}
+
+// Level is the level of the cell at column x of row y, 0 if empty.
+func (s Sprite) Level(x, y int) int {
+	if y < 0 || y >= len(s) || x < 0 || x >= len(s[y]) {
+		return 0
+	}
+	switch c := s[y][x]; {
+	case c == '#':
+		return 4
+	case c >= '1' && c <= '4':
+		return int(c - '0')
+	}
+	return 0
+}
+
+// SpriteNames lists the sprites in alphabetical order.
+func SpriteNames() []string {
+	names := make([]string, 0, len(Sprites))
+	for name := range Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetSprite looks a sprite up by name, ignoring case.
+func GetSprite(name string) (Sprite, bool) {
+	s, ok := Sprites[strings.ToLower(name)]
+	return s, ok
+}
@@ -6,6 +6,6 @@ export function Generate(arg1, arg2, arg3, arg4, arg5) {
   return window['go']['main']['App']['Generate'](arg1, arg2, arg3, arg4, arg5);
 }
 
-export function TextToPoints(arg1) {
-  return window['go']['main']['App']['TextToPoints'](arg1);
+export function TextToPoints(arg1, arg2) {
+  return window['go']['main']['App']['TextToPoints'](arg1, arg2);
 }
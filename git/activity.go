@@ -0,0 +1,40 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/levels"
+)
+
+// Activity counts the commits on the current branch of each repository at
+// paths by the day they were authored, which is how they show on the
+// contribution graph. With authors, only their commits are counted.
+func Activity(paths, authors []string) (levels.Counts, error) {
+	counts := levels.Counts{}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		r := &Repo{Path: path}
+		args := []string{"log", "--format=%ad", "--date=short"}
+		for _, a := range authors {
+			args = append(args, "--author="+regexp.QuoteMeta(a))
+		}
+		out, err := r.git(append(args, "HEAD")...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, line := range strings.Fields(out) {
+			d, err := time.Parse("2006-01-02", line)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad date %q", path, line)
+			}
+			counts.Add(d, 1)
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,120 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/levels"
+)
+
+// Open returns the repository at path, which must already exist.
+func Open(path string) (*Repo, error) {
+	r := &Repo{Path: path}
+	top, err := r.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	r.Path = top
+	return r, nil
+}
+
+// OpenRoot is Open for a path that must be the top of its repository. A
+// directory inside some other checkout is not taken for that checkout.
+func OpenRoot(path string) (*Repo, error) {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	if top, err := filepath.EvalSymlinks(r.Path); err != nil || top != abs {
+		return nil, fmt.Errorf("%s is inside the repository at %s, not a repository of its own", path, r.Path)
+	}
+	return r, nil
+}
+
+// TrailerCounts counts the commits on branch that carry trailer key, with
+// value unless it is empty, by the day they are dated. A branch without
+// commits has none.
+func (r *Repo) TrailerCounts(branch, key, value string) (levels.Counts, error) {
+	counts := levels.Counts{}
+	if _, err := r.git("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		return counts, nil
+	}
+	format := "%ad%x1f%(trailers:key=" + key + ",valueonly,separator=%x2C)"
+	out, err := r.git("log", "--format="+format, "--date=short", "refs/heads/"+branch, "--")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		date, values, ok := strings.Cut(line, "\x1f")
+		if !ok || strings.TrimSpace(values) == "" {
+			continue
+		}
+		if value != "" && !slices.Contains(strings.Split(values, ","), value) {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("git log: bad date %q", date)
+		}
+		counts.Add(d, 1)
+	}
+	return counts, nil
+}
+
+// Append commits cells on top of branch, creating it if it has no
+// commits yet. Unlike FastImportCells it keeps the history already there,
+// and files the commit style adds to start from their contents at the tip.
+// If branch is checked out, the work tree must be clean and is brought up
+// to date.
+func (r *Repo) Append(branch string, cells []draw.Cell, progress func(int, int)) error {
+	total := 0
+	for _, c := range cells {
+		total += c.Commits
+	}
+	if total == 0 {
+		return nil
+	}
+
+	current, _ := r.CurrentBranch()
+	if current == branch {
+		status, err := r.git("status", "--porcelain", "--untracked-files=no")
+		if err != nil {
+			return err
+		}
+		if status != "" {
+			return fmt.Errorf("working tree has uncommitted changes")
+		}
+	}
+
+	to := importTarget{ref: "refs/heads/" + branch}
+	if tip, err := r.git("rev-parse", "--verify", "--quiet", to.ref); err == nil {
+		to.from = tip
+		to.base = func(path string) []byte {
+			out, err := r.command("show", tip+":"+path).Output()
+			if err != nil {
+				return nil
+			}
+			return out
+		}
+	}
+	if err := r.fastImport(to, cells, total, 0, progress); err != nil {
+		return err
+	}
+
+	if current == branch {
+		_, err := r.git("reset", "--hard", "--quiet")
+		return err
+	}
+	return nil
+}
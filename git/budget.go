@@ -0,0 +1,113 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+// Limits that generated histories run into. GitHub rejects pushes over
+// 2 GB; long before that, big pushes over HTTPS tend to time out and very
+// long histories make the repository slow to clone and to render.
+const (
+	WarnCommits   = 10000
+	WarnPushBytes = 100 << 20
+	MaxPushBytes  = 2 << 30
+)
+
+// pushBytesPerCommit is the measured pack size of one generated commit,
+// including its tree and blob, for each content strategy, leaving out
+// what grows with the file it touches.
+var pushBytesPerCommit = map[string]int64{
+	ContentOverwrite: 230,
+	ContentAppend:    230,
+	ContentRotate:    230,
+	ContentDaily:     390,
+}
+
+// fileShare is the part of the file a commit touches that ends up in the
+// pack, as 1 in n bytes. Appending to the same file deltas well, but
+// fast-import deltas a blob against the one before it, which under rotate
+// is another file, so rotated files are mostly packed whole.
+var fileShare = map[string]int64{
+	ContentAppend: 100,
+	ContentRotate: 5,
+}
+
+// Budget is an estimate of what generating a plan costs.
+type Budget struct {
+	Commits    int      `json:"commits"`
+	Background int      `json:"background_commits"`
+	PushBytes  int64    `json:"push_bytes"`
+	RepoBytes  int64    `json:"repo_bytes"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// Estimate works out the size of the repository and of the push for cells
+// written in the given style. Background cells are passed separately so
+// the estimate can say how much of the plan they are.
+func Estimate(fgCells, bgCells []draw.Cell, style Style) Budget {
+	b := Budget{Background: draw.TotalCommits(bgCells)}
+	b.Commits = draw.TotalCommits(fgCells) + b.Background
+
+	per, ok := pushBytesPerCommit[style.Content]
+	if !ok {
+		per = pushBytesPerCommit[ContentOverwrite]
+	}
+	b.PushBytes = int64(b.Commits) * per
+	if share, ok := fileShare[style.Content]; ok {
+		b.PushBytes += fileBytes(append(append([]draw.Cell{}, bgCells...), fgCells...), style) / share
+	}
+	// the local pack is looser than the push, and the checkpoint keeps
+	// two marks per commit
+	b.RepoBytes = b.PushBytes*13/10 + int64(b.Commits)*90
+
+	if b.Commits > WarnCommits {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("%d commits is a lot: generating and pushing will be slow", b.Commits))
+	}
+	switch {
+	case b.PushBytes > MaxPushBytes:
+		b.Warnings = append(b.Warnings, fmt.Sprintf("a push of about %s is over GitHub's 2 GB limit", FormatBytes(b.PushBytes)))
+	case b.PushBytes > WarnPushBytes:
+		b.Warnings = append(b.Warnings, fmt.Sprintf("a push of about %s may time out over HTTPS", FormatBytes(b.PushBytes)))
+	}
+	return b
+}
+
+// fileBytes is the size of every file the commits for cells write, added
+// up, replaying the content writer the way the import does.
+func fileBytes(cells []draw.Cell, style Style) int64 {
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].Date.Before(cells[j].Date) })
+	files := newContentWriter(style)
+	total := draw.TotalCommits(cells)
+	var n int64
+	index := 0
+	for _, c := range cells {
+		for i := 0; i < c.Commits; i++ {
+			index++
+			f := Fields{Date: c.Date, Index: index, Total: total, Week: c.Week, Day: c.Day, Level: c.Level}
+			f.Message = style.message(f)
+			_, content := files.next(f)
+			n += int64(len(content))
+		}
+	}
+	return n
+}
+
+// Heavy reports whether the plan crosses any of the warning thresholds.
+func (b Budget) Heavy() bool {
+	return len(b.Warnings) > 0
+}
+
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
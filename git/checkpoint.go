@@ -0,0 +1,141 @@
+package git
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+const (
+	checkpointFile = "checkpoint.json"
+	marksFile      = "marks"
+)
+
+// Checkpoint records how far FastImportCells got with a plan. Done is
+// derived from the fast-import marks, so it only counts commits that
+// fast-import actually wrote to the branch.
+type Checkpoint struct {
+	Plan   string `json:"plan"`
+	Total  int    `json:"total"`
+	Done   int    `json:"done"`
+	Pushed bool   `json:"pushed"`
+}
+
+func (c *Checkpoint) Complete() bool {
+	return c.Done >= c.Total
+}
+
+// PlanHash identifies everything that affects the generated history: the
+// cells, the commit style, the design and the author.
+func (r *Repo) PlanHash(cells []draw.Cell) string {
+	name, email := r.author()
+	design := r.Design
+	if design != nil && design.Version == "" {
+		d := *design
+		d.Version = Version
+		design = &d
+	}
+	data, _ := json.Marshal(struct {
+		Version string
+		Author  string
+		Email   string
+		Style   Style
+		Design  *Design
+		Cells   []draw.Cell
+		Zone    string `json:",omitempty"`
+	}{Version, name, email, r.Style, design, cells, r.zone()})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Repo) zone() string {
+	if r.Location == nil {
+		return ""
+	}
+	return r.Location.String()
+}
+
+func (r *Repo) statePath(name string) string {
+	return filepath.Join(r.Path, ".git", stateDir, name)
+}
+
+// ReadCheckpoint returns the checkpoint left by the last FastImportCells
+// call, or nil if there is none.
+func (r *Repo) ReadCheckpoint() (*Checkpoint, error) {
+	data, err := os.ReadFile(r.statePath(checkpointFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint: %w", err)
+	}
+
+	mark, hash, err := r.lastCommitMark()
+	if err != nil {
+		return nil, err
+	}
+	cp.Done = 0
+	if mark > 0 {
+		tip, err := r.git("rev-parse", "--verify", "refs/heads/"+Branch)
+		if err != nil || tip != hash {
+			return nil, fmt.Errorf("branch %s no longer matches the checkpoint in %s", Branch, r.statePath(""))
+		}
+		cp.Done = (mark - 1) / 2
+	}
+	return &cp, nil
+}
+
+func (r *Repo) writeCheckpoint(cp *Checkpoint) error {
+	if err := os.MkdirAll(r.statePath(""), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.statePath(checkpointFile), append(data, '\n'), 0644)
+}
+
+// lastCommitMark returns the highest commit mark fast-import exported and
+// the commit it points at. Commit marks are odd, blob marks even.
+func (r *Repo) lastCommitMark() (int, string, error) {
+	f, err := os.Open(r.statePath(marksFile))
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	last, lastHash := 0, ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		mark, hash, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(mark, ":"))
+		if err != nil || n%2 == 0 {
+			continue
+		}
+		if n > last {
+			last, lastHash = n, hash
+		}
+	}
+	return last, lastHash, scanner.Err()
+}
@@ -0,0 +1,88 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+const (
+	DesignTrailer = "Gitdraw-Design"
+	NotesRef      = "refs/notes/gitdraw"
+)
+
+// Design is everything needed to reproduce a drawing. It is stored as a
+// note on the tip commit and its hash is added to every commit as
+// DesignTrailer.
+type Design struct {
+	Version    string    `json:"version"`
+	Year       int       `json:"year"`
+	Intensity  int       `json:"intensity"`
+	Background int       `json:"background"`
+	Grid       draw.Grid `json:"grid"`
+	// Calendar is the platform the design was laid out for, empty for
+	// GitHub.
+	Calendar string `json:"calendar,omitempty"`
+	// Shard names the part of the design a repository holds when it is
+	// split across several, empty for the whole design.
+	Shard string `json:"shard,omitempty"`
+}
+
+func (d *Design) JSON() []byte {
+	data, _ := json.Marshal(d)
+	return data
+}
+
+func (d *Design) Hash() string {
+	sum := sha256.Sum256(d.JSON())
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func (r *Repo) writeDesignNote(rev string) error {
+	cmd := exec.Command("git", "notes", "--ref", NotesRef, "add", "-f", "-F", "-", rev)
+	cmd.Dir = r.Path
+	cmd.Stdin = strings.NewReader(string(r.Design.JSON()))
+	cmd.Env = r.identityEnv()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git notes: %s", out)
+	}
+	return nil
+}
+
+// ReadDesign returns the design recorded on rev, or nil if rev has no
+// gitdraw note.
+func (r *Repo) ReadDesign(rev string) (*Design, error) {
+	commit, err := r.git("rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.git("rev-parse", "--verify", "--quiet", NotesRef); err != nil {
+		return nil, nil
+	}
+	// each line is a note and the object it is on
+	list, err := r.git("notes", "--ref", NotesRef, "list")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(list, "\n") {
+		note, object, _ := strings.Cut(line, " ")
+		if object != commit {
+			continue
+		}
+		out, err := r.git("cat-file", "blob", note)
+		if err != nil {
+			return nil, err
+		}
+		var d Design
+		if err := json.Unmarshal([]byte(out), &d); err != nil {
+			return nil, fmt.Errorf("design note on %s: %w", rev, err)
+		}
+		return &d, nil
+	}
+	return nil, nil
+}
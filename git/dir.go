@@ -0,0 +1,95 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stateDir lives inside .git and marks a repository created by Init. It
+// also holds any other bookkeeping gitdraw keeps for the repository.
+const stateDir = "gitdraw"
+
+type DirKind int
+
+const (
+	DirMissing DirKind = iota
+	DirEmpty
+	DirGitdraw
+	DirRepo
+	DirOther
+)
+
+func (k DirKind) String() string {
+	switch k {
+	case DirMissing:
+		return "missing"
+	case DirEmpty:
+		return "empty directory"
+	case DirGitdraw:
+		return "gitdraw repository"
+	case DirRepo:
+		return "git repository not created by gitdraw"
+	}
+	return "non-empty directory"
+}
+
+// Inspect reports what is at path so callers can decide whether it is
+// safe to delete. A repository only counts as DirGitdraw if it has the
+// gitdraw marker or gitdraw commits, and no commits of anyone else.
+func Inspect(path string) (DirKind, error) {
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return DirMissing, nil
+	}
+	if err != nil {
+		return DirOther, err
+	}
+	if len(entries) == 0 {
+		return DirEmpty, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		return DirOther, nil
+	}
+
+	repo := &Repo{Path: path}
+	marked := false
+	if _, err := os.Stat(filepath.Join(path, ".git", stateDir)); err == nil {
+		marked = true
+	}
+
+	drawn, err := repo.git("rev-list", "--branches", "--tags", "--count", "--grep=^"+MarkerTrailer+": ")
+	if err != nil {
+		return DirRepo, nil
+	}
+	others, err := repo.git("rev-list", "--branches", "--tags", "--count", "--invert-grep", "--grep=^"+MarkerTrailer+": ")
+	if err != nil {
+		return DirRepo, nil
+	}
+
+	if others == "0" && (marked || drawn != "0") {
+		return DirGitdraw, nil
+	}
+	return DirRepo, nil
+}
+
+// Backup renames path to a timestamped sibling and returns the new name.
+func Backup(path string) (string, error) {
+	path = strings.TrimRight(path, `/\`)
+	backup := fmt.Sprintf("%s.bak-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+func writeMarker(path string) error {
+	dir := filepath.Join(path, ".git", stateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "version"), []byte(Version+"\n"), 0644)
+}
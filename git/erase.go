@@ -0,0 +1,210 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupRef keeps the branch tip from before the last erase.
+const BackupRef = "refs/gitdraw/pre-erase"
+
+type LogEntry struct {
+	Hash    string
+	Parents []string
+	Date    time.Time
+	Subject string
+	Drawn   bool
+}
+
+func (r *Repo) git(args ...string) (string, error) {
+	cmd := r.command(args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CurrentBranch returns the branch HEAD points at.
+func (r *Repo) CurrentBranch() (string, error) {
+	return r.git("symbolic-ref", "--short", "HEAD")
+}
+
+// Log lists the commits reachable from branch, oldest first, marking the
+// ones that carry MarkerTrailer.
+func (r *Repo) Log(branch string) ([]LogEntry, error) {
+	format := "%H%x1f%P%x1f%at%x1f%s%x1f%(trailers:key=" + MarkerTrailer + ",valueonly,separator=%x2C)%x1e"
+	out, err := r.git("log", "--reverse", "--topo-order", "--format="+format, branch, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	for _, rec := range strings.Split(out, "\x1e") {
+		rec = strings.TrimSpace(rec)
+		if rec == "" {
+			continue
+		}
+		fields := strings.Split(rec, "\x1f")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("git log: unexpected output %q", rec)
+		}
+		ts, _ := strconv.ParseInt(fields[2], 10, 64)
+		entries = append(entries, LogEntry{
+			Hash:    fields[0],
+			Parents: strings.Fields(fields[1]),
+			Date:    time.Unix(ts, 0).UTC(),
+			Subject: fields[3],
+			Drawn:   strings.TrimSpace(fields[4]) != "",
+		})
+	}
+	return entries, nil
+}
+
+func Drawn(entries []LogEntry) []LogEntry {
+	var drawn []LogEntry
+	for _, e := range entries {
+		if e.Drawn {
+			drawn = append(drawn, e)
+		}
+	}
+	return drawn
+}
+
+type EraseMode int
+
+const (
+	EraseNothing EraseMode = iota
+	EraseReset
+	EraseRewrite
+	EraseAll
+)
+
+// ErasePlan describes how Erase will remove the gitdraw commits from a
+// branch. If the drawing sits on top of the branch it is reset to Base,
+// otherwise the branch is rebased with the drawn commits dropped.
+type ErasePlan struct {
+	Branch  string
+	Mode    EraseMode
+	Base    string
+	Drawn   []LogEntry
+	Replay  []LogEntry
+	OldHead string
+}
+
+func (r *Repo) PlanErase(branch string) (*ErasePlan, error) {
+	entries, err := r.Log(branch)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ErasePlan{Branch: branch, Drawn: Drawn(entries)}
+	if len(plan.Drawn) == 0 {
+		return plan, nil
+	}
+	plan.OldHead = entries[len(entries)-1].Hash
+
+	first := -1
+	for i, e := range entries {
+		if e.Drawn {
+			first = i
+			break
+		}
+	}
+	if parents := entries[first].Parents; len(parents) > 0 {
+		plan.Base = parents[0]
+	}
+	for _, e := range entries[first:] {
+		if !e.Drawn {
+			plan.Replay = append(plan.Replay, e)
+		}
+	}
+
+	if len(plan.Replay) == 0 {
+		plan.Mode = EraseReset
+		if plan.Base == "" {
+			plan.Mode = EraseAll
+		}
+	} else {
+		plan.Mode = EraseRewrite
+	}
+	return plan, nil
+}
+
+// Erase applies plan. The previous tip is saved under BackupRef. The work
+// tree must be clean because the branch is checked out while rewriting.
+func (r *Repo) Erase(plan *ErasePlan) error {
+	switch plan.Mode {
+	case EraseNothing:
+		return nil
+	case EraseAll:
+		return fmt.Errorf("every commit on %s was made by gitdraw; delete the branch or repository instead", plan.Branch)
+	}
+
+	status, err := r.git("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		return fmt.Errorf("working tree has uncommitted changes")
+	}
+
+	if _, err := r.git("update-ref", BackupRef, plan.OldHead); err != nil {
+		return err
+	}
+
+	if plan.Mode == EraseReset {
+		current, _ := r.CurrentBranch()
+		if current == plan.Branch {
+			_, err = r.git("reset", "--hard", plan.Base)
+		} else {
+			_, err = r.git("update-ref", "refs/heads/"+plan.Branch, plan.Base)
+		}
+		return err
+	}
+
+	return r.rebaseDropping(plan)
+}
+
+// rebaseDropping replays the branch onto the base with the drawn commits
+// dropped. Merges are recreated, so it lets git write the todo list, with
+// full hashes, and turns the drawn commits' picks into drops.
+func (r *Repo) rebaseDropping(plan *ErasePlan) error {
+	script, err := os.CreateTemp("", "gitdraw-drop-*.sed")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(script.Name())
+
+	for _, e := range plan.Drawn {
+		fmt.Fprintf(script, "s/^pick %s/drop %s/\n", e.Hash, e.Hash)
+	}
+	script.Close()
+
+	args := []string{"-c", "core.abbrev=no", "rebase", "-i", "--rebase-merges", "--empty=keep"}
+	if plan.Base == "" {
+		args = append(args, "--root", plan.Branch)
+	} else {
+		args = append(args, plan.Base, plan.Branch)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Path
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR=sed -i.orig -f "+shellQuote(filepath.ToSlash(script.Name())))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		abort := exec.Command("git", "rebase", "--abort")
+		abort.Dir = r.Path
+		abort.Run()
+		return fmt.Errorf("git rebase: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -1,16 +1,48 @@
 package git
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+const (
+	// Branch is the local branch every drawing is written to.
+	Branch        = "main"
+	DefaultRemote = "origin"
 )
 
+// MarkerTrailer is added to every generated commit so gitdraw can find its
+// own commits again later.
+const MarkerTrailer = "Gitdraw-Version"
+
+// Version is written as the value of MarkerTrailer.
+var Version = "dev"
+
 type Repo struct {
-	Path string
+	Path   string
+	Author string
+	Email  string
+	Style  Style
+	Design *Design
+
+	// Location is the timezone commits are dated in. The wall clock times
+	// of the plan are kept, so commits land on the same calendar days.
+	// Nil means UTC.
+	Location *time.Location
+
+	// Trailers are extra "Key: value" lines added to every commit.
+	Trailers []string
+
+	// Context, if set, stops the git commands the repo runs when it is
+	// done, so a long import or push can be cancelled.
+	Context context.Context
 }
 
 func Init(path string) (*Repo, error) {
@@ -29,6 +61,16 @@ func Init(path string) (*Repo, error) {
 		return nil, fmt.Errorf("git init: %s", out)
 	}
 
+	head := exec.Command("git", "symbolic-ref", "HEAD", "refs/heads/"+Branch)
+	head.Dir = abs
+	if out, err := head.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git symbolic-ref: %s", out)
+	}
+
+	if err := writeMarker(abs); err != nil {
+		return nil, err
+	}
+
 	return &Repo{Path: abs}, nil
 }
 
@@ -66,16 +108,113 @@ func (r *Repo) FastImport(dates []time.Time, intensity int, progress func(int, i
 }
 
 func (r *Repo) FastImportLayers(bgDates []time.Time, bgIntensity int, fgDates []time.Time, fgIntensity int, progress func(int, int)) error {
-	name, email := getGitUser()
-	if name == "" {
-		name = "gitdraw"
+	return r.FastImportCells(LayerCells(bgDates, bgIntensity, fgDates, fgIntensity), progress)
+}
+
+// LayerCells turns a background and a foreground date list into cells,
+// background first.
+func LayerCells(bgDates []time.Time, bgIntensity int, fgDates []time.Time, fgIntensity int) []draw.Cell {
+	cells := make([]draw.Cell, 0, len(bgDates)+len(fgDates))
+	for _, d := range bgDates {
+		cells = append(cells, dateCell(d, 1, bgIntensity))
 	}
-	if email == "" {
-		email = "gitdraw@local"
+	for _, d := range fgDates {
+		cells = append(cells, dateCell(d, 4, fgIntensity))
 	}
+	return cells
+}
 
-	cmd := exec.Command("git", "fast-import", "--quiet")
-	cmd.Dir = r.Path
+func dateCell(d time.Time, level, commits int) draw.Cell {
+	p := draw.Locate(draw.GitHub{}, d.Year(), d)
+	return draw.Cell{Week: p.Week, Day: p.Day, Level: level, Date: d, Commits: commits}
+}
+
+// checkpointEvery is how many commits fast-import writes between
+// checkpoints, i.e. how much work an interrupted run can lose.
+const checkpointEvery = 500
+
+// FastImportCells is the commit writer shared by the CLI and the GUI. Every
+// cell gets Commits commits spread across its day, with messages and file
+// contents produced by r.Style.
+//
+// Progress is checkpointed in the repository, so calling it again with the
+// same cells after an interruption continues where the last run stopped,
+// and calling it after a complete run does nothing.
+func (r *Repo) FastImportCells(cells []draw.Cell, progress func(int, int)) error {
+	total := 0
+	for _, c := range cells {
+		total += c.Commits
+	}
+
+	if r.Design != nil && r.Design.Version == "" {
+		r.Design.Version = Version
+	}
+
+	plan := r.PlanHash(cells)
+	cp, err := r.ReadCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cp == nil || cp.Plan != plan {
+		if _, err := r.git("rev-parse", "--verify", "--quiet", "refs/heads/"+Branch); err == nil {
+			return fmt.Errorf("branch %s already has commits from a different design", Branch)
+		}
+		if err := os.Remove(r.statePath(marksFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		cp = &Checkpoint{Plan: plan, Total: total}
+	}
+	if err := r.writeCheckpoint(cp); err != nil {
+		return err
+	}
+
+	if cp.Done < total {
+		marks, err := filepath.Abs(r.statePath(marksFile))
+		if err != nil {
+			return err
+		}
+		to := importTarget{ref: "refs/heads/" + Branch, marks: marks}
+		if err := r.fastImport(to, cells, total, cp.Done, progress); err != nil {
+			return err
+		}
+		cp.Done = total
+		if err := r.writeCheckpoint(cp); err != nil {
+			return err
+		}
+	} else if progress != nil && total > 0 {
+		progress(total, total)
+	}
+
+	if r.Design != nil && total > 0 {
+		return r.writeDesignNote("refs/heads/" + Branch)
+	}
+	return nil
+}
+
+// importTarget is where fastImport writes commits.
+type importTarget struct {
+	ref string
+	// from is the commit the first new commit follows, empty to start a
+	// new history or to continue from the marks.
+	from string
+	// marks is the file the commit marks are kept in, so an interrupted
+	// import can continue. Empty for none.
+	marks string
+	// base returns a file's contents at from, for the content writer to
+	// add to.
+	base func(path string) []byte
+}
+
+func (r *Repo) fastImport(to importTarget, cells []draw.Cell, total, done int, progress func(int, int)) error {
+	name, email := r.author()
+	args := []string{"fast-import", "--quiet"}
+	if to.marks != "" {
+		args = append(args, "--export-marks="+to.marks)
+		if done > 0 {
+			args = append(args, "--import-marks="+to.marks)
+		}
+	}
+	cmd := r.command(args...)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -86,42 +225,70 @@ func (r *Repo) FastImportLayers(bgDates []time.Time, bgIntensity int, fgDates []
 		return err
 	}
 
-	total := len(bgDates)*bgIntensity + len(fgDates)*fgIntensity
+	trailers := "\n\n" + MarkerTrailer + ": " + Version
+	if r.Design != nil {
+		trailers += "\n" + DesignTrailer + ": " + r.Design.Hash()
+	}
+	for _, t := range r.Trailers {
+		trailers += "\n" + t
+	}
+
+	files := newContentWriter(r.Style)
+	files.base = to.base
 	count := 0
 	var parentMark int
+	if done > 0 {
+		parentMark = done*2 + 1
+	}
 
-	writeCommit := func(d time.Time) {
+	writeCommit := func(c draw.Cell, d time.Time) {
 		count++
-		ts := d.Unix() + int64(count)
-		content := fmt.Sprintf("%d\n", ts)
-		msg := fmt.Sprintf("draw %d/%d", count, total)
+		f := Fields{Date: d, Index: count, Total: total, Week: c.Week, Day: c.Day, Level: c.Level}
+		f.Message = r.Style.message(f)
+		path, content := files.next(f)
+		if count <= done {
+			// already imported, but the content writer still has to see it
+			return
+		}
+		msg := f.Message + trailers
 
 		blobMark := count * 2
 		commitMark := count*2 + 1
 
 		fmt.Fprintf(stdin, "blob\nmark :%d\ndata %d\n%s\n", blobMark, len(content), content)
-		fmt.Fprintf(stdin, "commit refs/heads/main\nmark :%d\n", commitMark)
-		fmt.Fprintf(stdin, "author %s <%s> %d +0000\n", name, email, d.Unix())
-		fmt.Fprintf(stdin, "committer %s <%s> %d +0000\n", name, email, d.Unix())
+		fmt.Fprintf(stdin, "commit %s\nmark :%d\n", to.ref, commitMark)
+		when := r.localTime(d)
+		fmt.Fprintf(stdin, "author %s <%s> %d %s\n", name, email, when.Unix(), when.Format("-0700"))
+		fmt.Fprintf(stdin, "committer %s <%s> %d %s\n", name, email, when.Unix(), when.Format("-0700"))
 		fmt.Fprintf(stdin, "data %d\n%s\n", len(msg), msg)
 
 		if parentMark > 0 {
 			fmt.Fprintf(stdin, "from :%d\n", parentMark)
+		} else if to.from != "" {
+			fmt.Fprintf(stdin, "from %s\n", to.from)
 		}
-		fmt.Fprintf(stdin, "M 100644 :%d gitdraw.txt\n\n", blobMark)
+		fmt.Fprintf(stdin, "M 100644 :%d %s\n\n", blobMark, quotePath(path))
 		parentMark = commitMark
-		progress(count, total)
-	}
-
-	for _, d := range bgDates {
-		for i := 0; i < bgIntensity; i++ {
-			writeCommit(d.Add(time.Duration(i) * time.Hour))
+		if count%checkpointEvery == 0 {
+			fmt.Fprintf(stdin, "checkpoint\n\n")
+		}
+		if progress != nil {
+			progress(count, total)
 		}
 	}
 
-	for _, d := range fgDates {
-		for i := 0; i < fgIntensity; i++ {
-			writeCommit(d.Add(time.Duration(i) * time.Hour))
+	for _, c := range cells {
+		if r.Context != nil && r.Context.Err() != nil {
+			break
+		}
+		// keep every commit for a cell on the same calendar day, even at
+		// intensities above 12
+		step := time.Hour
+		if c.Commits > 12 {
+			step = 12 * time.Hour / time.Duration(c.Commits)
+		}
+		for i := 0; i < c.Commits; i++ {
+			writeCommit(c, c.Date.Add(time.Duration(i)*step))
 		}
 	}
 
@@ -129,7 +296,55 @@ func (r *Repo) FastImportLayers(bgDates []time.Time, bgIntensity int, fgDates []
 	return cmd.Wait()
 }
 
-func getGitUser() (string, string) {
+func (r *Repo) command(args ...string) *exec.Cmd {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Path
+	return cmd
+}
+
+func (r *Repo) localTime(d time.Time) time.Time {
+	if r.Location == nil {
+		return d.UTC()
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), 0, r.Location)
+}
+
+func (r *Repo) author() (string, string) {
+	name, email := r.Author, r.Email
+	if name == "" || email == "" {
+		gitName, gitEmail := User()
+		if name == "" {
+			name = gitName
+		}
+		if email == "" {
+			email = gitEmail
+		}
+	}
+	if name == "" {
+		name = "gitdraw"
+	}
+	if email == "" {
+		email = "gitdraw@local"
+	}
+	return name, email
+}
+
+func (r *Repo) identityEnv() []string {
+	name, email := r.author()
+	return append(os.Environ(),
+		"GIT_AUTHOR_NAME="+name,
+		"GIT_AUTHOR_EMAIL="+email,
+		"GIT_COMMITTER_NAME="+name,
+		"GIT_COMMITTER_EMAIL="+email,
+	)
+}
+
+// User returns the name and email from the git config.
+func User() (string, string) {
 	name, _ := exec.Command("git", "config", "user.name").Output()
 	email, _ := exec.Command("git", "config", "user.email").Output()
 	return strings.TrimSpace(string(name)), strings.TrimSpace(string(email))
@@ -138,7 +353,7 @@ func getGitUser() (string, string) {
 func (r *Repo) CommitAll(dates []time.Time) error {
 	total := len(dates)
 	for i, d := range dates {
-		msg := fmt.Sprintf("gitdraw: %d/%d", i+1, total)
+		msg := fmt.Sprintf("gitdraw: %d/%d\n\n%s: %s", i+1, total, MarkerTrailer, Version)
 		if err := r.Commit(d, msg); err != nil {
 			return err
 		}
@@ -148,24 +363,63 @@ func (r *Repo) CommitAll(dates []time.Time) error {
 	return nil
 }
 
-func (r *Repo) AddRemote(url string) error {
-	cmd := exec.Command("git", "remote", "add", "origin", url)
-	cmd.Dir = r.Path
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("%s", out)
+func (r *Repo) AddRemote(name, url string) error {
+	if name == "" {
+		name = DefaultRemote
 	}
-	return nil
+	// a retried run may already have added the remote
+	if _, err := r.git("remote", "get-url", name); err == nil {
+		_, err = r.git("remote", "set-url", name, url)
+		return err
+	}
+	_, err := r.git("remote", "add", name, url)
+	return err
 }
 
-func (r *Repo) Push() error {
-	cmd := exec.Command("git", "branch", "-M", "main")
-	cmd.Dir = r.Path
-	cmd.CombinedOutput()
+// HasRemote reports whether the repository has a remote called name.
+func (r *Repo) HasRemote(name string) bool {
+	_, err := r.git("remote", "get-url", name)
+	return err == nil
+}
+
+func (r *Repo) Push(opts PushOptions) error {
+	remote, branch := opts.Remote, opts.Branch
+	if remote == "" {
+		remote = DefaultRemote
+	}
+	if branch == "" {
+		branch = Branch
+	}
+
+	args := []string{"push", "-u"}
+	switch opts.Force {
+	case ForceWithLease:
+		args = append(args, "--force-with-lease=refs/heads/"+branch)
+	case ForceAlways:
+		args = append(args, "--force")
+	}
+	local := opts.Local
+	if local == "" {
+		local = Branch
+	}
+	args = append(args, remote, "refs/heads/"+local+":refs/heads/"+branch)
+	// the design notes go along, replaced whenever the branch they
+	// describe is
+	if _, err := r.git("rev-parse", "--verify", "--quiet", NotesRef); err == nil {
+		notes := NotesRef + ":" + NotesRef
+		if opts.Force != ForceNone {
+			notes = "+" + notes
+		}
+		args = append(args, notes)
+	}
+
+	if _, err := r.git(args...); err != nil {
+		return err
+	}
 
-	push := exec.Command("git", "push", "-u", "origin", "main")
-	push.Dir = r.Path
-	if out, err := push.CombinedOutput(); err != nil {
-		return fmt.Errorf("%s", out)
+	if cp, err := r.ReadCheckpoint(); err == nil && cp != nil {
+		cp.Pushed = true
+		return r.writeCheckpoint(cp)
 	}
 	return nil
 }
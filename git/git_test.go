@@ -0,0 +1,629 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func bareRemote(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "remote.git")
+	run(t, t.TempDir(), "init", "--bare", dir)
+	return "file://" + filepath.ToSlash(dir)
+}
+
+func drawnRepo(t *testing.T, remote string, days int) *Repo {
+	t.Helper()
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dates []time.Time
+	for i := 0; i < days; i++ {
+		dates = append(dates, time.Date(2020, 3, 1+i, 12, 0, 0, 0, time.UTC))
+	}
+	if err := repo.FastImport(dates, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddRemote("", remote); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func remoteHead(t *testing.T, remote, branch string) string {
+	t.Helper()
+	out := run(t, t.TempDir(), "ls-remote", remote, "refs/heads/"+branch)
+	hash, _, _ := strings.Cut(out, "\t")
+	return hash
+}
+
+func localHead(t *testing.T, repo *Repo) string {
+	t.Helper()
+	return run(t, repo.Path, "rev-parse", "refs/heads/"+Branch)
+}
+
+func TestPushBranch(t *testing.T) {
+	remote := bareRemote(t)
+	repo := drawnRepo(t, remote, 3)
+
+	if err := repo.Push(PushOptions{Branch: "drawing"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := remoteHead(t, remote, "drawing"), localHead(t, repo); got != want {
+		t.Fatalf("remote drawing = %q, want %q", got, want)
+	}
+	if got := remoteHead(t, remote, Branch); got != "" {
+		t.Fatalf("main should not exist on remote, got %q", got)
+	}
+}
+
+func TestPushForceModes(t *testing.T) {
+	remote := bareRemote(t)
+	first := drawnRepo(t, remote, 3)
+	second := drawnRepo(t, remote, 5)
+
+	if err := first.Push(PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := second.Push(PushOptions{})
+	if err == nil {
+		t.Fatal("push of unrelated history succeeded without force")
+	}
+	if !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("error does not carry git output: %v", err)
+	}
+
+	if err := second.Push(PushOptions{Force: ForceAlways}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := remoteHead(t, remote, Branch), localHead(t, second); got != want {
+		t.Fatalf("remote main = %q, want %q", got, want)
+	}
+
+	// first still believes the remote is at its own tip
+	if err := first.Push(PushOptions{Force: ForceWithLease}); err == nil {
+		t.Fatal("lease push over a newer remote succeeded")
+	}
+
+	run(t, first.Path, "fetch", DefaultRemote)
+	if err := first.Push(PushOptions{Force: ForceWithLease}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := remoteHead(t, remote, Branch), localHead(t, first); got != want {
+		t.Fatalf("remote main = %q, want %q", got, want)
+	}
+}
+
+func TestDesignNote(t *testing.T) {
+	remote := bareRemote(t)
+	repo := drawnRepo(t, remote, 3)
+	if d, err := repo.ReadDesign(Branch); d != nil || err != nil {
+		t.Fatalf("repo without notes: %v, %v", d, err)
+	}
+	if _, err := repo.ReadDesign("nope"); err == nil {
+		t.Error("reading the design of a missing revision succeeded")
+	}
+
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Design = &Design{Year: 2020, Intensity: 3}
+	day := time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC)
+	if err := repo.FastImportCells([]draw.Cell{{Date: day, Level: 4, Commits: 2}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	d, err := repo.ReadDesign(Branch)
+	if err != nil || d == nil || d.Hash() != repo.Design.Hash() {
+		t.Fatalf("ReadDesign = %v, %v", d, err)
+	}
+	// only the tip has the note
+	if d, err := repo.ReadDesign(Branch + "~1"); d != nil || err != nil {
+		t.Errorf("first commit: %v, %v", d, err)
+	}
+
+	if err := repo.AddRemote("", remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(PushOptions{Force: ForceAlways}); err != nil {
+		t.Fatal(err)
+	}
+	notes, _, _ := strings.Cut(run(t, t.TempDir(), "ls-remote", remote, NotesRef), "\t")
+	if want := run(t, repo.Path, "rev-parse", NotesRef); notes != want {
+		t.Errorf("remote notes = %q, want %q", notes, want)
+	}
+
+	run(t, repo.Path, "-c", "user.name=me", "-c", "user.email=me@example.com", "notes", "--ref", NotesRef, "add", "-f", "-m", "not json", Branch)
+	if _, err := repo.ReadDesign(Branch); err == nil {
+		t.Error("a broken note read without an error")
+	}
+}
+
+func TestParseForce(t *testing.T) {
+	for in, want := range map[string]Force{"": ForceNone, "none": ForceNone, "lease": ForceWithLease, "force": ForceAlways} {
+		got, err := ParseForce(in)
+		if err != nil || got != want {
+			t.Errorf("ParseForce(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseForce("yes"); err == nil {
+		t.Error("ParseForce(yes) succeeded")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	base := t.TempDir()
+	if kind, _ := Inspect(filepath.Join(base, "missing")); kind != DirMissing {
+		t.Errorf("missing dir: got %s", kind)
+	}
+
+	drawn := drawnRepo(t, bareRemote(t), 2)
+	if kind, _ := Inspect(drawn.Path); kind != DirGitdraw {
+		t.Errorf("generated repo: got %s", kind)
+	}
+
+	other := t.TempDir()
+	run(t, other, "init")
+	run(t, other, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "--allow-empty", "-m", "real work")
+	if kind, _ := Inspect(other); kind != DirRepo {
+		t.Errorf("foreign repo: got %s", kind)
+	}
+
+	run(t, drawn.Path, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "--allow-empty", "-m", "real work")
+	if kind, _ := Inspect(drawn.Path); kind != DirRepo {
+		t.Errorf("generated repo with foreign commits: got %s", kind)
+	}
+}
+
+func TestFastImportResume(t *testing.T) {
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Style = Style{Content: ContentAppend}
+
+	var dates []time.Time
+	for i := 0; i < 6; i++ {
+		dates = append(dates, time.Date(2020, 3, 1+i, 12, 0, 0, 0, time.UTC))
+	}
+	cells := LayerCells(nil, 0, dates, 3)
+	if err := repo.FastImportCells(cells, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := localHead(t, repo)
+
+	// pretend fast-import died after the fifth commit
+	const kept = 5
+	marks, err := os.ReadFile(repo.statePath(marksFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var partial []string
+	var tip string
+	for _, line := range strings.Split(strings.TrimSpace(string(marks)), "\n") {
+		var mark int
+		var hash string
+		fmt.Sscanf(line, ":%d %s", &mark, &hash)
+		if mark <= kept*2+1 {
+			partial = append(partial, line)
+		}
+		if mark == kept*2+1 {
+			tip = hash
+		}
+	}
+	os.WriteFile(repo.statePath(marksFile), []byte(strings.Join(partial, "\n")+"\n"), 0644)
+	run(t, repo.Path, "update-ref", "refs/heads/"+Branch, tip)
+
+	cp, err := repo.ReadCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Done != kept || cp.Complete() {
+		t.Fatalf("checkpoint = %+v, want %d done", cp, kept)
+	}
+
+	var first int
+	err = repo.FastImportCells(cells, func(done, total int) {
+		if first == 0 {
+			first = done
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != kept+1 {
+		t.Errorf("resumed at commit %d, want %d", first, kept+1)
+	}
+	if got := localHead(t, repo); got != want {
+		t.Errorf("resumed tip = %s, want %s", got, want)
+	}
+
+	if err := repo.FastImportCells(cells, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := localHead(t, repo); got != want {
+		t.Errorf("re-run changed tip to %s", got)
+	}
+
+	other := LayerCells(nil, 0, dates, 4)
+	if err := repo.FastImportCells(other, nil); err == nil {
+		t.Error("importing a different design over an existing one succeeded")
+	}
+}
+
+func TestFastImportEmpty(t *testing.T) {
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an empty plan, such as a year still to come, makes no commits and
+	// has no progress to report
+	err = repo.FastImportCells(nil, func(done, total int) {
+		t.Errorf("progress(%d, %d) for an empty plan", done, total)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContentParts(t *testing.T) {
+	const commits = 6000
+	for _, style := range []Style{
+		{Content: ContentAppend},
+		{Content: ContentRotate, Files: []string{"notes.md", "todo"}},
+	} {
+		w := newContentWriter(style)
+		day := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+		files := map[string]string{}
+		for i := 1; i <= commits; i++ {
+			path, content := w.next(Fields{Index: i, Date: day, Message: "some words to fill the line"})
+			if len(content) > maxFileSize {
+				t.Fatalf("%s: commit %d wrote %d bytes to %s", style.Content, i, len(content), path)
+			}
+			if len(content) < len(files[path]) {
+				t.Fatalf("%s: commit %d went back to the full %s", style.Content, i, path)
+			}
+			files[path] = string(content)
+		}
+		// every entry is kept, in parts numbered from the second
+		lines := 0
+		for _, name := range w.style.Files {
+			for k := 1; files[partPath(name, k)] != ""; k++ {
+				lines += strings.Count(files[partPath(name, k)], "\n")
+			}
+		}
+		if len(files) < 2*len(w.style.Files) || lines != commits {
+			t.Errorf("%s: %d lines in %d files", style.Content, lines, len(files))
+		}
+	}
+
+	// an append to a history whose file is already full carries on in the
+	// next part
+	w := newContentWriter(Style{Content: ContentAppend})
+	w.base = func(path string) []byte {
+		if path == "journal.md" {
+			return make([]byte, maxFileSize)
+		}
+		return nil
+	}
+	if path, _ := w.next(Fields{Index: 1}); path != "journal-2.md" {
+		t.Errorf("appended to %s", path)
+	}
+	if got := partPath("notes.d/log", 2); got != "notes.d/log-2" {
+		t.Errorf("partPath = %q", got)
+	}
+}
+
+func TestFastImportLocation(t *testing.T) {
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Location = time.FixedZone("UTC-8", -8*3600)
+
+	date := time.Date(2020, 3, 1, 20, 0, 0, 0, time.UTC)
+	if err := repo.FastImportCells(LayerCells(nil, 0, []time.Time{date}, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(t, repo.Path, "log", "-1", "--format=%ai"); got != "2020-03-01 20:00:00 -0800" {
+		t.Errorf("author date = %s", got)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	var fg, bg []draw.Cell
+	fg = append(fg, draw.Cell{Level: 4, Commits: 20000})
+	bg = append(bg, draw.Cell{Commits: 300})
+
+	b := Estimate(fg, bg, Style{})
+	if b.Commits != 20300 || b.Background != 300 {
+		t.Errorf("got %d commits, %d background", b.Commits, b.Background)
+	}
+	if !b.Heavy() {
+		t.Error("20300 commits not flagged")
+	}
+	if b := Estimate(fg[:0], bg, Style{}); b.Heavy() {
+		t.Errorf("300 commits flagged: %v", b.Warnings)
+	}
+
+	// files that grow with the history cost more per commit, as measured
+	// on 16000 commits
+	fg[0].Commits = 16000
+	for content, want := range map[string][2]int64{
+		ContentOverwrite: {200, 300},
+		ContentAppend:    {300, 600},
+		ContentRotate:    {4000, 7000},
+	} {
+		b := Estimate(fg, nil, Style{Content: content})
+		if per := b.PushBytes / 16000; per < want[0] || per > want[1] {
+			t.Errorf("%s: %d bytes a commit, want %d to %d", content, per, want[0], want[1])
+		}
+	}
+}
+
+func TestActivity(t *testing.T) {
+	repo := drawnRepo(t, bareRemote(t), 3)
+	counts, err := Activity([]string{repo.Path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC)
+	if len(counts) != 3 || counts.Get(day) != 2 {
+		t.Errorf("activity = %v", counts)
+	}
+
+	counts, err = Activity([]string{repo.Path}, []string{"someone.else@example.com"})
+	if err != nil || len(counts) != 0 {
+		t.Errorf("another author's activity = %v, %v", counts, err)
+	}
+	if _, err := Activity([]string{filepath.Join(t.TempDir(), "missing")}, nil); err == nil {
+		t.Error("missing repository accepted")
+	}
+}
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	run(t, dir, "init", "-q", "-b", "trunk")
+	os.WriteFile(filepath.Join(dir, "journal.md"), []byte("by hand\n"), 0644)
+	run(t, dir, "add", ".")
+	run(t, dir, "-c", "user.name=me", "-c", "user.email=me@example.com", "commit", "-q", "-m", "mine")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Style = Style{Content: ContentAppend}
+	repo.Trailers = []string{"Gitdraw-Test: yes"}
+	day := time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC)
+	cells := []draw.Cell{{Date: day, Level: 4, Commits: 2}, {Date: day.AddDate(0, 0, 1), Level: 4, Commits: 1}}
+	if err := repo.Append("trunk", cells, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := run(t, dir, "rev-list", "--count", "trunk"); n != "4" {
+		t.Errorf("%s commits, want the 3 added on top of the first", n)
+	}
+	// the work tree follows the checked out branch, and appended files
+	// keep what was there
+	data, _ := os.ReadFile(filepath.Join(dir, "journal.md"))
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 4 || lines[0] != "by hand" {
+		t.Errorf("journal.md = %q", data)
+	}
+	if status := run(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("work tree is dirty: %s", status)
+	}
+
+	counts, err := repo.TrailerCounts("trunk", "Gitdraw-Test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts.Get(day) != 2 {
+		t.Errorf("trailer counts = %v", counts)
+	}
+	if counts, _ := repo.TrailerCounts("trunk", "Gitdraw-Test", "no"); len(counts) != 0 {
+		t.Errorf("counts of another value: %v", counts)
+	}
+	if counts, _ := repo.TrailerCounts("none", "Gitdraw-Test", ""); len(counts) != 0 {
+		t.Errorf("a missing branch has counts %v", counts)
+	}
+
+	os.WriteFile(filepath.Join(dir, "journal.md"), []byte("changed\n"), 0644)
+	if err := repo.Append("trunk", cells, nil); err == nil {
+		t.Error("appended over uncommitted changes")
+	}
+}
+
+func TestErase(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "me")
+	t.Setenv("GIT_AUTHOR_EMAIL", "me@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "me")
+	t.Setenv("GIT_COMMITTER_EMAIL", "me@example.com")
+	commit := func(dir, file string) {
+		os.WriteFile(filepath.Join(dir, file), []byte(file+"\n"), 0644)
+		run(t, dir, "add", ".")
+		run(t, dir, "commit", "-q", "-m", file)
+	}
+	drawn := func(dir string) *Repo {
+		t.Helper()
+		run(t, dir, "init", "-q", "-b", "trunk")
+		commit(dir, "a.txt")
+		repo, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		day := time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC)
+		if err := repo.Append("trunk", []draw.Cell{{Date: day, Level: 4, Commits: 2}}, nil); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}
+
+	// a drawing on top is reset away
+	dir := t.TempDir()
+	repo := drawn(dir)
+	plan, err := repo.PlanErase("trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Mode != EraseReset || len(plan.Drawn) != 2 {
+		t.Fatalf("plan = %v with %d drawn", plan.Mode, len(plan.Drawn))
+	}
+	if err := repo.Erase(plan); err != nil {
+		t.Fatal(err)
+	}
+	if n := run(t, dir, "rev-list", "--count", "trunk"); n != "1" {
+		t.Errorf("%s commits left, want 1", n)
+	}
+
+	// work merged on top of a drawing keeps its merge
+	dir = t.TempDir()
+	repo = drawn(dir)
+	run(t, dir, "checkout", "-q", "-b", "side")
+	commit(dir, "side.txt")
+	run(t, dir, "checkout", "-q", "trunk")
+	commit(dir, "b.txt")
+	run(t, dir, "merge", "-q", "--no-edit", "side")
+	commit(dir, "c.txt")
+	old := run(t, dir, "rev-parse", "trunk")
+
+	plan, err = repo.PlanErase("trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Mode != EraseRewrite || len(plan.Drawn) != 2 || len(plan.Replay) != 4 {
+		t.Fatalf("plan = %v with %d drawn, %d replayed", plan.Mode, len(plan.Drawn), len(plan.Replay))
+	}
+	if err := repo.Erase(plan); err != nil {
+		t.Fatal(err)
+	}
+	if n := run(t, dir, "rev-list", "--count", "trunk"); n != "5" {
+		t.Errorf("%s commits left, want 5", n)
+	}
+	if n := run(t, dir, "rev-list", "--count", "--merges", "trunk"); n != "1" {
+		t.Errorf("%s merges left, want 1", n)
+	}
+	if files := run(t, dir, "ls-tree", "--name-only", "trunk"); files != "a.txt\nb.txt\nc.txt\nside.txt" {
+		t.Errorf("files after erase: %q", files)
+	}
+	if entries, _ := repo.Log("trunk"); len(Drawn(entries)) != 0 {
+		t.Errorf("%d drawn commits left", len(Drawn(entries)))
+	}
+	if backup := run(t, dir, "rev-parse", BackupRef); backup != old {
+		t.Errorf("backup = %s, want %s", backup, old)
+	}
+	if status := run(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("work tree is dirty: %s", status)
+	}
+}
+
+func TestOpenRoot(t *testing.T) {
+	repo, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRoot(repo.Path); err != nil {
+		t.Errorf("top of a repository: %v", err)
+	}
+
+	// a directory inside the checkout is not its repository
+	inside := filepath.Join(repo.Path, "schedule")
+	if err := os.Mkdir(inside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRoot(inside); err == nil {
+		t.Error("opened the enclosing repository")
+	}
+	if _, err := Init(inside); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := OpenRoot(inside); err != nil || r.Path == repo.Path {
+		t.Errorf("nested repository: %v %v", r, err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	f := Fields{
+		Date:    time.Date(2024, 2, 29, 15, 4, 0, 0, time.UTC),
+		Index:   3,
+		Total:   40,
+		Week:    8,
+		Day:     4,
+		Level:   2,
+		Message: "hello",
+	}
+	for _, c := range []struct{ tmpl, want string }{
+		{"{date}", "2024-02-29"},
+		{"{date:Jan 2 15:04}", "Feb 29 15:04"},
+		{"{date:2006/01/02}.md", "2024/02/29.md"},
+		{"{index}/{total}", "3/40"},
+		{"w{week} d{day}", "w8 d4"},
+		{"{weekday}", "Thursday"},
+		{"level {level}", "level 2"},
+		{"> {message}", "> hello"},
+		{"{nope} {index}", "{nope} 3"},
+		{"{index:x}", "3"},
+		{"{index", "{index"},
+		{"{index} {total", "3 {total"},
+		{"}{index}{", "}3{"},
+		{"", ""},
+	} {
+		if got := Expand(c.tmpl, f); got != c.want {
+			t.Errorf("Expand(%q) = %q, want %q", c.tmpl, got, c.want)
+		}
+	}
+}
+
+func TestContentFiles(t *testing.T) {
+	day := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	paths := func(style Style, commits int) []string {
+		w := newContentWriter(style)
+		var got []string
+		for i := 1; i <= commits; i++ {
+			path, _ := w.next(Fields{Index: i, Date: day.AddDate(0, 0, i/2)})
+			got = append(got, path)
+		}
+		return got
+	}
+
+	if got := strings.Join(paths(Style{Content: ContentRotate}, 4), " "); got != "notes.md todo.md log.md notes.md" {
+		t.Errorf("rotate wrote %s", got)
+	}
+	if got := strings.Join(paths(Style{Content: ContentDaily}, 4), " "); got != "2024/02/29.md 2024/03/01.md 2024/03/01.md 2024/03/02.md" {
+		t.Errorf("daily wrote %s", got)
+	}
+	if got := strings.Join(paths(Style{Content: ContentDaily, Files: []string{"log/{date:2006-01}.txt"}}, 2), " "); got != "log/2024-02.txt log/2024-03.txt" {
+		t.Errorf("daily with a template wrote %s", got)
+	}
+
+	// 64 KB of appends roll over to journal-2.md, then journal-3.md
+	w := newContentWriter(Style{Content: ContentAppend, Entry: strings.Repeat("x", 1023)})
+	seen := map[string]int{}
+	for i := 1; i <= 130; i++ {
+		path, _ := w.next(Fields{Index: i})
+		seen[path]++
+	}
+	if len(seen) != 3 || seen["journal.md"] != 64 || seen["journal-2.md"] != 64 || seen["journal-3.md"] != 2 {
+		t.Errorf("append parts = %v", seen)
+	}
+}
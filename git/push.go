@@ -0,0 +1,42 @@
+package git
+
+import "fmt"
+
+type Force int
+
+const (
+	ForceNone Force = iota
+	ForceWithLease
+	ForceAlways
+)
+
+type PushOptions struct {
+	Remote string
+	Branch string
+	Force  Force
+	// Local is the branch pushed, Branch of the repository if empty.
+	Local string
+}
+
+// ParseForce accepts "none", "lease" or "force".
+func ParseForce(s string) (Force, error) {
+	switch s {
+	case "", "none":
+		return ForceNone, nil
+	case "lease", "force-with-lease":
+		return ForceWithLease, nil
+	case "force":
+		return ForceAlways, nil
+	}
+	return ForceNone, fmt.Errorf("unknown force mode: %s", s)
+}
+
+func (f Force) String() string {
+	switch f {
+	case ForceWithLease:
+		return "lease"
+	case ForceAlways:
+		return "force"
+	}
+	return "none"
+}
@@ -0,0 +1,209 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContentOverwrite = "overwrite"
+	ContentAppend    = "append"
+	ContentRotate    = "rotate"
+	ContentDaily     = "daily"
+)
+
+const (
+	DefaultMessage = "draw {index}/{total}"
+	DefaultEntry   = "{date:2006-01-02 15:04} {message}"
+)
+
+var defaultFiles = map[string][]string{
+	ContentOverwrite: {"gitdraw.txt"},
+	ContentAppend:    {"journal.md"},
+	ContentRotate:    {"notes.md", "todo.md", "log.md"},
+	ContentDaily:     {"{date:2006/01/02}.md"},
+}
+
+// Style controls what generated commits look like. Message and Entry are
+// templates over Fields; Files are path templates whose use depends on
+// Content:
+//
+//	overwrite  rewrite Files[0] with a timestamp (the original behaviour)
+//	append     append an Entry line to Files[0]
+//	rotate     append an Entry line to each of Files in turn
+//	daily      append an Entry line to Files[0], expanded per commit date
+type Style struct {
+	Message string
+	Content string
+	Entry   string
+	Files   []string
+}
+
+// Fields are the values available to templates as {date}, {index},
+// {total}, {week}, {day}, {weekday}, {level} and {message}. {date} takes
+// an optional Go time layout, e.g. {date:Jan 2}.
+type Fields struct {
+	Date    time.Time
+	Index   int
+	Total   int
+	Week    int
+	Day     int
+	Level   int
+	Message string
+}
+
+func ValidContent(content string) bool {
+	_, ok := defaultFiles[content]
+	return ok
+}
+
+func (s Style) message(f Fields) string {
+	tmpl := s.Message
+	if tmpl == "" {
+		tmpl = DefaultMessage
+	}
+	return Expand(tmpl, f)
+}
+
+// Expand replaces {name} and {name:arg} placeholders in tmpl. Unknown
+// placeholders are left untouched.
+func Expand(tmpl string, f Fields) string {
+	return ExpandFunc(tmpl, f.lookup)
+}
+
+// ExpandFunc is Expand with placeholders looked up by lookup, which gets
+// what is between the braces and reports whether it knows it.
+func ExpandFunc(tmpl string, lookup func(key string) (string, bool)) string {
+	var sb strings.Builder
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			break
+		}
+		end += open
+
+		sb.WriteString(tmpl[:open])
+		if v, ok := lookup(tmpl[open+1 : end]); ok {
+			sb.WriteString(v)
+		} else {
+			sb.WriteString(tmpl[open : end+1])
+		}
+		tmpl = tmpl[end+1:]
+	}
+	sb.WriteString(tmpl)
+	return sb.String()
+}
+
+func (f Fields) lookup(key string) (string, bool) {
+	name, arg, _ := strings.Cut(key, ":")
+	switch name {
+	case "date":
+		if arg == "" {
+			arg = "2006-01-02"
+		}
+		return f.Date.UTC().Format(arg), true
+	case "index":
+		return strconv.Itoa(f.Index), true
+	case "total":
+		return strconv.Itoa(f.Total), true
+	case "week":
+		return strconv.Itoa(f.Week), true
+	case "day":
+		return strconv.Itoa(f.Day), true
+	case "weekday":
+		return f.Date.UTC().Weekday().String(), true
+	case "level":
+		return strconv.Itoa(f.Level), true
+	case "message":
+		return f.Message, true
+	}
+	return "", false
+}
+
+// maxFileSize is how big an appended file grows before the entries
+// carry on in a new one. Every commit stores the whole file it touches, so
+// without a cap a long history is quadratic in its number of commits.
+const maxFileSize = 64 << 10
+
+type contentWriter struct {
+	style Style
+	files map[string][]byte
+	// parts is the part each appended file has got to.
+	parts map[string]int
+	// base, if set, returns what a file held before the first commit.
+	base func(path string) []byte
+}
+
+func newContentWriter(style Style) *contentWriter {
+	if style.Content == "" {
+		style.Content = ContentOverwrite
+	}
+	if len(style.Files) == 0 {
+		style.Files = defaultFiles[style.Content]
+	}
+	if style.Entry == "" {
+		style.Entry = DefaultEntry
+	}
+	return &contentWriter{style: style, files: make(map[string][]byte), parts: make(map[string]int)}
+}
+
+// next returns the path touched by the commit described by f and the full
+// new contents of that file.
+func (w *contentWriter) next(f Fields) (string, []byte) {
+	files := w.style.Files
+	if w.style.Content == ContentOverwrite {
+		path := Expand(files[0], f)
+		return path, []byte(fmt.Sprintf("%d\n", f.Date.Unix()+int64(f.Index)))
+	}
+
+	path := files[0]
+	if w.style.Content == ContentRotate {
+		path = files[(f.Index-1)%len(files)]
+	}
+	path = Expand(path, f)
+
+	line := Expand(w.style.Entry, f) + "\n"
+	path = w.part(path, len(line))
+	w.files[path] = append(w.files[path], line...)
+	return path, w.files[path]
+}
+
+// part is the file n more bytes of path go in: path itself until it is
+// full, then path-2, path-3 and so on. Full parts are never touched again.
+func (w *contentWriter) part(path string, n int) string {
+	for k := max(w.parts[path], 1); ; k++ {
+		p := partPath(path, k)
+		if _, ok := w.files[p]; !ok && w.base != nil {
+			w.files[p] = w.base(p)
+		}
+		if size := len(w.files[p]); size == 0 || size+n <= maxFileSize {
+			w.parts[path] = k
+			return p
+		}
+		delete(w.files, p)
+	}
+}
+
+// partPath names the kth part of a file, numbering before the extension:
+// journal.md, journal-2.md, journal-3.md.
+func partPath(name string, k int) string {
+	if k == 1 {
+		return name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), k, ext)
+}
+
+func quotePath(path string) string {
+	if strings.HasPrefix(path, "\"") || strings.ContainsAny(path, "\n") {
+		return strconv.Quote(path)
+	}
+	return path
+}
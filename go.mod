@@ -4,7 +4,11 @@ go 1.22.0
 
 toolchain go1.23.2
 
-require github.com/wailsapp/wails/v2 v2.11.0
+require (
+	github.com/wailsapp/wails/v2 v2.11.0
+	golang.org/x/sys v0.30.0
+	golang.org/x/term v0.29.0
+)
 
 require (
 	github.com/bep/debounce v1.2.1 // indirect
@@ -32,6 +36,5 @@ require (
 	github.com/wailsapp/mimetype v1.4.1 // indirect
 	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/net v0.35.0 // indirect
-	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 )
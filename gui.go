@@ -4,15 +4,10 @@ package main
 
 import (
 	"context"
-	"embed"
-	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
-	"time"
 
-	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/config"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -20,315 +15,16 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
 )
 
-//go:embed gui
-var assets embed.FS
-
-type App struct {
-	ctx context.Context
-}
-
-func NewApp() *App {
-	return &App{}
-}
-
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
 
-type Point struct {
-	Week  int `json:"week"`
-	Day   int `json:"day"`
-	Level int `json:"level"`
-}
-
-func (a *App) TextToPoints(text string) string {
-	grid := draw.Text(strings.ToUpper(text))
-	points := grid.Points()
-	
-	result := make([]Point, len(points))
-	for i, p := range points {
-		result[i] = Point{Week: p.Week, Day: p.Day}
-	}
-	
-	jsonData, _ := json.Marshal(result)
-	return string(jsonData)
-}
-
-func (a *App) Generate(pointsJSON string, year, intensity int, fillBg bool, remoteURL string) string {
-	var points []Point
-	if err := json.Unmarshal([]byte(pointsJSON), &points); err != nil {
-		return "error: invalid points data (corrupted)"
-	}
-
-	tmpDir, err := os.MkdirTemp("", "gitdraw-*")
-	if err != nil {
-		return "error: failed to create temp directory (probs no space left)"
-	}
-
-	if err := gitInit(tmpDir); err != nil {
-		return "error: git init failed (probs git not installed)"
-	}
-
-	name, email := getGitUser()
-	if name == "" {
-		name = "gitdraw"
-	}
-	if email == "" {
-		return "error: git user.email not configured (try 'git config --global user.email')"
-	}
-
-	fgDateIntensities := pointsToDateIntensities(points, year, intensity)
-	var bgDates []time.Time
-
-	if fillBg {
-		bgDates = backgroundDates(points, year)
-	}
-
-	if err := fastImportWithLevels(tmpDir, bgDates, 1, fgDateIntensities, name, email); err != nil {
-		return "error: commit generation failed"
-	}
-
-	if remoteURL != "" {
-		if !strings.Contains(remoteURL, "://") && !strings.HasPrefix(remoteURL, "git@") {
-			remoteURL = "https://" + remoteURL
-		}
-
-		if err := gitAddRemote(tmpDir, remoteURL); err != nil {
-			return "error: failed to add remote"
-		}
-
-		if err := gitPush(tmpDir); err != nil {
-			return "error: push failed - check your credentials"
-		}
-	}
-
-	os.RemoveAll(tmpDir)
-	return "success"
-}
-
-func graphStart(year int) time.Time {
-	dec31 := time.Date(year, 12, 31, 12, 0, 0, 0, time.UTC)
-	daysSinceSunday := int(dec31.Weekday())
-	lastSunday := dec31.AddDate(0, 0, -daysSinceSunday)
-	return lastSunday.AddDate(0, 0, -52*7)
-}
-
-type DateIntensity struct {
-	Date      time.Time
-	Intensity int
-}
-
-func pointsToDateIntensities(points []Point, year int, baseIntensity int) []DateIntensity {
-	start := graphStart(year)
-	now := time.Now()
-	var result []DateIntensity
-
-	for _, p := range points {
-		d := start.AddDate(0, 0, p.Week*7+p.Day)
-		if d.Year() == year && !d.After(now) {
-			// lv 1-4
-			// lv 4 = full
-			level := p.Level
-			if level < 1 {
-				level = 1
-			}
-			if level > 4 {
-				level = 4
-			}
-			cellIntensity := (baseIntensity * level) / 4
-			if cellIntensity < 1 {
-				cellIntensity = 1
-			}
-			result = append(result, DateIntensity{Date: d, Intensity: cellIntensity})
-		}
-	}
-	return result
-}
-
-func pointsToDates(points []Point, year int) []time.Time {
-	start := graphStart(year)
-	now := time.Now()
-	var dates []time.Time
-
-	for _, p := range points {
-		d := start.AddDate(0, 0, p.Week*7+p.Day)
-		if d.Year() == year && !d.After(now) {
-			dates = append(dates, d)
-		}
-	}
-	return dates
-}
-
-func backgroundDates(fgPoints []Point, year int) []time.Time {
-	start := graphStart(year)
-	now := time.Now()
-
-	fgSet := make(map[string]bool)
-	for _, p := range fgPoints {
-		fgSet[fmt.Sprintf("%d-%d", p.Week, p.Day)] = true
-	}
-
-	var dates []time.Time
-	for week := 0; week < 53; week++ {
-		for day := 0; day < 7; day++ {
-			if !fgSet[fmt.Sprintf("%d-%d", week, day)] {
-				d := start.AddDate(0, 0, week*7+day)
-				if d.Year() == year && !d.After(now) {
-					dates = append(dates, d)
-				}
-			}
-		}
-	}
-	return dates
-}
-
-func gitInit(path string) error {
-	cmd := exec.Command("git", "init")
-	cmd.Dir = path
-	return cmd.Run()
-}
-
-func getGitUser() (string, string) {
-	name, _ := exec.Command("git", "config", "user.name").Output()
-	email, _ := exec.Command("git", "config", "user.email").Output()
-	return strings.TrimSpace(string(name)), strings.TrimSpace(string(email))
-}
-
-func fastImport(repoPath string, bgDates []time.Time, bgIntensity int, fgDates []time.Time, fgIntensity int, name, email string) error {
-	cmd := exec.Command("git", "fast-import", "--quiet")
-	cmd.Dir = repoPath
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	total := len(bgDates)*bgIntensity + len(fgDates)*fgIntensity
-	count := 0
-	var parentMark int
-
-	writeCommit := func(d time.Time) {
-		count++
-		ts := d.Unix() + int64(count)
-		content := fmt.Sprintf("%d\n", ts)
-		msg := fmt.Sprintf("draw %d/%d", count, total)
-
-		blobMark := count * 2
-		commitMark := count*2 + 1
-
-		fmt.Fprintf(stdin, "blob\nmark :%d\ndata %d\n%s\n", blobMark, len(content), content)
-		fmt.Fprintf(stdin, "commit refs/heads/main\nmark :%d\n", commitMark)
-		fmt.Fprintf(stdin, "author %s <%s> %d +0000\n", name, email, d.Unix())
-		fmt.Fprintf(stdin, "committer %s <%s> %d +0000\n", name, email, d.Unix())
-		fmt.Fprintf(stdin, "data %d\n%s\n", len(msg), msg)
-
-		if parentMark > 0 {
-			fmt.Fprintf(stdin, "from :%d\n", parentMark)
-		}
-		fmt.Fprintf(stdin, "M 100644 :%d gitdraw.txt\n\n", blobMark)
-		parentMark = commitMark
-	}
-
-	for _, d := range bgDates {
-		for i := 0; i < bgIntensity; i++ {
-			writeCommit(d.Add(time.Duration(i) * time.Hour))
-		}
-	}
-
-	for _, d := range fgDates {
-		for i := 0; i < fgIntensity; i++ {
-			writeCommit(d.Add(time.Duration(i) * time.Hour))
-		}
-	}
-
-	stdin.Close()
-	return cmd.Wait()
-}
-
-func fastImportWithLevels(repoPath string, bgDates []time.Time, bgIntensity int, fgDateIntensities []DateIntensity, name, email string) error {
-	cmd := exec.Command("git", "fast-import", "--quiet")
-	cmd.Dir = repoPath
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	total := len(bgDates) * bgIntensity
-	for _, di := range fgDateIntensities {
-		total += di.Intensity
-	}
-	count := 0
-	var parentMark int
-
-	writeCommit := func(d time.Time) {
-		count++
-		ts := d.Unix() + int64(count)
-		content := fmt.Sprintf("%d\n", ts)
-		msg := fmt.Sprintf("draw %d/%d", count, total)
-
-		blobMark := count * 2
-		commitMark := count*2 + 1
-
-		fmt.Fprintf(stdin, "blob\nmark :%d\ndata %d\n%s\n", blobMark, len(content), content)
-		fmt.Fprintf(stdin, "commit refs/heads/main\nmark :%d\n", commitMark)
-		fmt.Fprintf(stdin, "author %s <%s> %d +0000\n", name, email, d.Unix())
-		fmt.Fprintf(stdin, "committer %s <%s> %d +0000\n", name, email, d.Unix())
-		fmt.Fprintf(stdin, "data %d\n%s\n", len(msg), msg)
-
-		if parentMark > 0 {
-			fmt.Fprintf(stdin, "from :%d\n", parentMark)
-		}
-		fmt.Fprintf(stdin, "M 100644 :%d gitdraw.txt\n\n", blobMark)
-		parentMark = commitMark
-	}
-
-	for _, d := range bgDates {
-		for i := 0; i < bgIntensity; i++ {
-			writeCommit(d.Add(time.Duration(i) * time.Hour))
-		}
-	}
-
-	for _, di := range fgDateIntensities {
-		for i := 0; i < di.Intensity; i++ {
-			writeCommit(di.Date.Add(time.Duration(i) * time.Hour))
-		}
-	}
-
-	stdin.Close()
-	return cmd.Wait()
-}
-
-func gitAddRemote(path, url string) error {
-	cmd := exec.Command("git", "remote", "add", "origin", url)
-	cmd.Dir = path
-	return cmd.Run()
-}
-
-func gitPush(path string) error {
-	branch := exec.Command("git", "branch", "-M", "main")
-	branch.Dir = path
-	branch.Run()
-
-	push := exec.Command("git", "push", "-u", "origin", "main", "--force")
-	push.Dir = path
-	return push.Run()
-}
-
 func main() {
 	if len(os.Args) > 1 {
 		arg := os.Args[1]
 		switch arg {
 		case "--cli", "-c":
-			runCLI()
+			runCLI(os.Args[2:])
 			return
 		case "--help", "-h":
 			printHelpGUI()
@@ -336,10 +32,37 @@ func main() {
 		case "--version", "-v":
 			printVersionGUI()
 			return
+		case "erase":
+			runErase(os.Args[2:])
+			return
+		case "edit":
+			runEdit(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "sprites":
+			runSprites(os.Args[2:])
+			return
+		case "marquee":
+			runMarquee(os.Args[2:])
+			return
+		case "schedule":
+			runSchedule(os.Args[2:])
+			return
 		}
 	}
 
-	runGUI()
+	var configPath string
+	if len(os.Args) > 2 && os.Args[1] == "--config" {
+		configPath = os.Args[2]
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		os.Exit(1)
+	}
+	runGUI(cfg)
 }
 
 func printHelpGUI() {
@@ -348,7 +71,22 @@ func printHelpGUI() {
 
   Usage:
     gitdraw          Launch GUI (default)
-    gitdraw --cli    Run interactive CLI
+    gitdraw --config <file>
+                     Launch GUI with defaults from a config file
+    gitdraw --cli [options]
+                     Run interactive CLI (same options as the CLI build)
+    gitdraw erase <repo>
+                     Remove gitdraw commits from a repository
+    gitdraw edit [file]
+                     Paint a design in the terminal, then generate it
+    gitdraw serve [--addr host:port]
+                     HTTP/JSON API and the editor in a browser
+    gitdraw sprites [name...]
+                     List and preview the sprites for :name: in text
+    gitdraw marquee --text <msg> --start <date> <repo>
+                     Scroll a message one column a week, run daily
+    gitdraw schedule add <name> [design.json] [options]
+                     Draw a design in real time, see schedule tick and run
 
   Flags:
     -c, --cli      Use command-line interface
@@ -358,11 +96,11 @@ func printHelpGUI() {
 }
 
 func printVersionGUI() {
-	fmt.Println("gitdraw v1.0.0")
+	fmt.Println("gitdraw v" + version)
 }
 
-func runGUI() {
-	app := NewApp()
+func runGUI(cfg config.Config) {
+	app := NewApp(cfg)
 
 	err := wails.Run(&options.App{
 		Title:     "GitDraw",
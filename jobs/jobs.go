@@ -0,0 +1,351 @@
+// Package jobs queues generations so several designs can be written and
+// pushed in the background, each in its own working directory.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+)
+
+type State string
+
+const (
+	Queued  State = "queued"
+	Running State = "running"
+	Pushing State = "pushing"
+	Done    State = "done"
+	Failed  State = "failed"
+)
+
+// DefaultLimit is how many jobs run at once unless told otherwise.
+const DefaultLimit = 2
+
+const (
+	historyFile = "history.json"
+	lockFile    = "lock"
+)
+
+// ErrCancelled is the error of a job that was cancelled.
+var ErrCancelled = fmt.Errorf("cancelled")
+
+// Job is the state of one generation, as kept in the history.
+type Job struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	State   State     `json:"state"`
+	Done    int       `json:"done"`
+	Total   int       `json:"total"`
+	Error   string    `json:"error,omitempty"`
+	Dir     string    `json:"dir"`
+	Remote  string    `json:"remote,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Finished reports whether the job has stopped, for good or bad.
+func (j Job) Finished() bool {
+	return j.State == Done || j.State == Failed
+}
+
+// Spec is what a job generates: Repo supplies the author, style, design
+// and timezone, and gets its Path from the job. Cells are written with
+// FastImportCells and pushed to Remote, if set.
+type Spec struct {
+	Repo   git.Repo
+	Cells  []draw.Cell
+	Remote string
+	Push   git.PushOptions
+}
+
+type entry struct {
+	job     Job
+	spec    Spec
+	cancel  context.CancelFunc
+	changed chan struct{}
+}
+
+// Manager runs jobs in the order they were submitted, at most limit at a
+// time, and keeps their history in dir. Only one Manager at a time can
+// have a dir, as job IDs and the history are not shared between them.
+type Manager struct {
+	dir   string
+	limit int
+	lock  *os.File
+
+	mu      sync.Mutex
+	jobs    map[string]*entry
+	order   []string
+	running int
+	last    int
+}
+
+// DefaultDir is the jobs directory in the user's config dir.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gitdraw", "jobs"), nil
+}
+
+// NewManager locks dir and loads its history, failing if another process
+// has it. Jobs that were still queued or running when the last process
+// stopped are marked failed. The lock is held until Close or the process
+// exits.
+func NewManager(dir string, limit int) (*Manager, error) {
+	if limit < 1 {
+		limit = DefaultLimit
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "work"), 0755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := tryLock(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("jobs in %s are in use by another gitdraw", dir)
+	}
+	m := &Manager{dir: dir, limit: limit, lock: lock, jobs: make(map[string]*entry)}
+
+	data, err := os.ReadFile(filepath.Join(dir, historyFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var history []Job
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &history); err != nil {
+			return nil, fmt.Errorf("job history: %v", err)
+		}
+	}
+	for _, j := range history {
+		if !j.Finished() {
+			j.State, j.Error = Failed, "interrupted"
+		}
+		m.jobs[j.ID] = &entry{job: j, changed: make(chan struct{})}
+		m.order = append(m.order, j.ID)
+		if n, _ := strconv.Atoi(j.ID); n > m.last {
+			m.last = n
+		}
+	}
+	return m, nil
+}
+
+// Close releases dir for another Manager. Jobs still running carry on
+// but should be waited for first, as the next Manager marks them failed.
+func (m *Manager) Close() error {
+	return m.lock.Close()
+}
+
+// Submit queues a job and returns it.
+func (m *Manager) Submit(name string, spec Spec) Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// an ID is taken once its work dir is made, so one left behind by
+	// a lost history is not written over
+	var id string
+	for {
+		m.last++
+		id = strconv.Itoa(m.last)
+		if err := os.Mkdir(filepath.Join(m.dir, "work", id), 0755); !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	now := time.Now()
+	e := &entry{
+		job: Job{
+			ID:      id,
+			Name:    name,
+			State:   Queued,
+			Total:   draw.TotalCommits(spec.Cells),
+			Dir:     filepath.Join(m.dir, "work", id),
+			Remote:  spec.Remote,
+			Created: now,
+			Updated: now,
+		},
+		spec:    spec,
+		changed: make(chan struct{}),
+	}
+	m.jobs[id] = e
+	m.order = append(m.order, id)
+	m.save()
+	m.dispatch()
+	return e.job
+}
+
+// Get returns a job by ID.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return e.job, true
+}
+
+// List returns every job, newest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		list = append(list, m.jobs[m.order[i]].job)
+	}
+	return list
+}
+
+// Watch returns a job and a channel that is closed when it next changes.
+func (m *Manager) Watch(id string) (Job, <-chan struct{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	return e.job, e.changed, true
+}
+
+// Cancel stops a queued or running job. The working directory of a job
+// that was stopped part way is kept for inspection.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	switch {
+	case !ok:
+		return fmt.Errorf("no job %s", id)
+	case e.job.Finished():
+		return fmt.Errorf("job %s already %s", id, e.job.State)
+	case e.job.State == Queued:
+		m.update(e, func(j *Job) { j.State, j.Error = Failed, ErrCancelled.Error() })
+	default:
+		e.cancel()
+	}
+	return nil
+}
+
+// dispatch starts queued jobs, oldest first, while there is room. The
+// caller holds m.mu.
+func (m *Manager) dispatch() {
+	for _, id := range m.order {
+		if m.running >= m.limit {
+			return
+		}
+		e := m.jobs[id]
+		if e.job.State != Queued {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		e.cancel = cancel
+		m.running++
+		m.update(e, func(j *Job) { j.State = Running })
+		go m.run(ctx, e)
+	}
+}
+
+func (m *Manager) run(ctx context.Context, e *entry) {
+	err := m.generate(ctx, e)
+	if ctx.Err() != nil {
+		err = ErrCancelled
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.cancel()
+	m.running--
+	m.update(e, func(j *Job) {
+		j.State = Done
+		if err != nil {
+			j.State, j.Error = Failed, err.Error()
+		}
+	})
+	m.dispatch()
+}
+
+func (m *Manager) generate(ctx context.Context, e *entry) error {
+	repo := e.spec.Repo
+	repo.Path = e.job.Dir
+	repo.Context = ctx
+
+	if _, err := git.Init(repo.Path); err != nil {
+		return err
+	}
+
+	last := -1
+	err := repo.FastImportCells(e.spec.Cells, func(done, total int) {
+		// one update per percent keeps watchers from being flooded
+		if total == 0 {
+			return
+		}
+		if pct := done * 100 / total; pct != last {
+			last = pct
+			m.progress(e, done, total)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if e.spec.Remote == "" {
+		return nil
+	}
+	m.mu.Lock()
+	m.update(e, func(j *Job) { j.State = Pushing })
+	m.mu.Unlock()
+
+	if err := repo.AddRemote(e.spec.Push.Remote, e.spec.Remote); err != nil {
+		return err
+	}
+	if err := repo.Push(e.spec.Push); err != nil {
+		return err
+	}
+	// a pushed drawing lives on the remote, the working copy is done with
+	return os.RemoveAll(repo.Path)
+}
+
+func (m *Manager) progress(e *entry, done, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update(e, func(j *Job) { j.Done, j.Total = done, total })
+}
+
+// update changes a job, wakes its watchers and, if the state changed,
+// saves the history. The caller holds m.mu.
+func (m *Manager) update(e *entry, fn func(j *Job)) {
+	before := e.job.State
+	fn(&e.job)
+	e.job.Updated = time.Now()
+	close(e.changed)
+	e.changed = make(chan struct{})
+	if e.job.State != before {
+		m.save()
+	}
+}
+
+// save writes the history. The caller holds m.mu; errors are ignored as
+// the history is only a record.
+func (m *Manager) save() {
+	history := make([]Job, 0, len(m.order))
+	for _, id := range m.order {
+		history = append(history, m.jobs[id].job)
+	}
+	data, _ := json.MarshalIndent(history, "", "  ")
+	tmp := filepath.Join(m.dir, historyFile+".tmp")
+	if os.WriteFile(tmp, data, 0644) == nil {
+		os.Rename(tmp, filepath.Join(m.dir, historyFile))
+	}
+}
@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+)
+
+func spec(days, count int) Spec {
+	var cells []draw.Cell
+	for i := 0; i < days; i++ {
+		cells = append(cells, draw.Cell{Date: time.Date(2020, 3, 1+i, 12, 0, 0, 0, time.UTC), Commits: count})
+	}
+	return Spec{Repo: git.Repo{Author: "test", Email: "test@example.com"}, Cells: cells}
+}
+
+// wait blocks until the job has finished.
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	timeout := time.After(30 * time.Second)
+	for {
+		j, changed, ok := m.Watch(id)
+		if !ok {
+			t.Fatalf("no job %s", id)
+		}
+		if j.Finished() {
+			return j
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("job %s still %s", id, j.State)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %s", out)
+	}
+
+	m, err := NewManager(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed := spec(3, 2)
+	pushed.Remote = "file://" + filepath.ToSlash(remote)
+	first := m.Submit("pushed", pushed)
+	second := m.Submit("local", spec(2, 1))
+	if second.State != Queued {
+		t.Errorf("second job is %s with a limit of 1, want queued", second.State)
+	}
+
+	j := wait(t, m, first.ID)
+	if j.State != Done || j.Done != 6 || j.Total != 6 {
+		t.Fatalf("first job = %+v", j)
+	}
+	if _, err := os.Stat(j.Dir); !os.IsNotExist(err) {
+		t.Errorf("work dir of a pushed job kept")
+	}
+	out, _ := exec.Command("git", "--git-dir", remote, "rev-list", "--count", "main").Output()
+	if got := strings.TrimSpace(string(out)); got != "6" {
+		t.Errorf("remote has %s commits, want 6", got)
+	}
+
+	j = wait(t, m, second.ID)
+	if j.State != Done {
+		t.Fatalf("second job = %+v", j)
+	}
+	if _, err := os.Stat(filepath.Join(j.Dir, ".git")); err != nil {
+		t.Errorf("work dir of an unpushed job: %v", err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewManager(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := reloaded.List()
+	if len(list) != 2 || list[0].ID != second.ID || list[1].State != Done {
+		t.Errorf("reloaded history = %+v", list)
+	}
+	next := reloaded.Submit("next", spec(1, 1))
+	if next.ID != "3" {
+		t.Errorf("next ID = %s, want 3", next.ID)
+	}
+	wait(t, reloaded, next.ID)
+}
+
+func TestEmpty(t *testing.T) {
+	m, err := NewManager(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a design for a year still to come has no commits yet
+	j := wait(t, m, m.Submit("empty", spec(0, 0)).ID)
+	if j.State != Done || j.Done != 0 {
+		t.Errorf("empty job = %+v", j)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m, err := NewManager(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running := m.Submit("big", spec(300, 40))
+	queued := m.Submit("queued", spec(1, 1))
+
+	if err := m.Cancel(queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Get(queued.ID); j.State != Failed || j.Error != ErrCancelled.Error() {
+		t.Errorf("cancelled queued job = %+v", j)
+	}
+	if err := m.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	j := wait(t, m, running.ID)
+	if j.State != Failed || j.Error != ErrCancelled.Error() {
+		t.Errorf("cancelled running job = %+v", j)
+	}
+	if err := m.Cancel(running.ID); err == nil {
+		t.Error("cancelling a finished job succeeded")
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	history := `[{"id":"7","state":"running","created":"2024-01-01T00:00:00Z"}]`
+	if err := os.WriteFile(filepath.Join(dir, historyFile), []byte(history), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Get("7"); j.State != Failed || j.Error != "interrupted" {
+		t.Errorf("job left running = %+v", j)
+	}
+	next := m.Submit("next", spec(1, 1))
+	if next.ID != "8" {
+		t.Errorf("next ID = %s, want 8", next.ID)
+	}
+	wait(t, m, next.ID)
+}
+
+func TestSharedDir(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewManager(dir, 1); err == nil {
+		t.Error("a second manager took the same dir")
+	}
+
+	// a work dir the history does not know of keeps its ID
+	if err := os.Mkdir(filepath.Join(dir, "work", "1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	j := m.Submit("next", spec(1, 1))
+	if j.ID != "2" {
+		t.Errorf("ID = %s, want 2", j.ID)
+	}
+	wait(t, m, j.ID)
+}
@@ -0,0 +1,474 @@
+// Package levels models how platforms shade the contribution graph, and
+// works out the commits that make a design come out at the wanted levels.
+//
+// GitHub does not use fixed buckets. It takes the days of the displayed
+// year that have contributions, sorts their counts and uses the quartiles
+// as thresholds: a day at or below the first quartile is level 1, at or
+// below the median level 2, at or below the third quartile level 3, and
+// above it level 4. The levels therefore depend on the whole year,
+// including contributions that are already there.
+package levels
+
+import (
+	"sort"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+// Thresholds are the highest counts shown at levels 1, 2 and 3.
+type Thresholds [3]int
+
+// Quartiles computes the thresholds for a year of daily counts, using the
+// nearest rank among the days with contributions.
+func Quartiles(counts []int) Thresholds {
+	return quartiles(counts, nil)
+}
+
+// quartiles is Quartiles sorting in buf, to save allocating in Solve.
+func quartiles(counts, buf []int) Thresholds {
+	active := buf[:0]
+	for _, c := range counts {
+		if c > 0 {
+			active = append(active, c)
+		}
+	}
+	var t Thresholds
+	if len(active) == 0 {
+		return t
+	}
+	sort.Ints(active)
+	for k := range t {
+		// nearest rank: the smallest count with at least (k+1)/4 of the days
+		// at or below it
+		rank := ((k+1)*len(active) + 3) / 4
+		t[k] = active[max(rank, 1)-1]
+	}
+	return t
+}
+
+// Level is the level a day with count contributions is shown at.
+func (t Thresholds) Level(count int) int {
+	if count <= 0 {
+		return 0
+	}
+	for i, limit := range t {
+		if count <= limit {
+			return i + 1
+		}
+	}
+	return draw.MaxLevel
+}
+
+// Levels shades a year of daily counts.
+func Levels(s Shading, counts []int) []int {
+	t := s.Thresholds(counts)
+	out := make([]int, len(counts))
+	for i, c := range counts {
+		out[i] = t.Level(c)
+	}
+	return out
+}
+
+// Solution is the outcome of solving for a design.
+type Solution struct {
+	// Add is the number of commits to add on each day.
+	Add []int
+	// Thresholds are the year's thresholds once they are added.
+	Thresholds Thresholds
+	// Unsatisfiable lists the days that still show another level than
+	// wanted. There is no way to get them right: either they already
+	// have more contributions than their level allows, or, on GitHub,
+	// the levels asked for cannot all be quartiles of one year, such as a
+	// year where every active day is level 4.
+	Unsatisfiable []int
+}
+
+// Solve finds the fewest commits to add to each day of a GitHub year so
+// that day i shows at level target[i], given the counts already there. Days at level
+// 4 get at least top commits in all, so designs keep a chosen depth; 0
+// asks for the bare minimum. When not every day can be satisfied, Solve
+// returns the commits that get the most days right.
+//
+// Every quartile is one of the year's counts, and the counts are either
+// existing ones or an end of a level's range under the quartiles. So Solve
+// tries each set of thresholds drawn from the small counts and the
+// existing ones, lifts the days of each level to the bottom or, when the
+// ranks need it, the top of the level's range, and keeps the cheapest
+// choice under which the year really has those thresholds. Lifting only
+// ever costs more as the thresholds rise, so once every day that can be
+// is satisfied the search stops at the first thresholds too dear to beat
+// it.
+func Solve(target, existing []int, top int) Solution {
+	target = append([]int(nil), target...)
+	for i, level := range target {
+		target[i] = min(max(level, 0), draw.MaxLevel)
+	}
+	y := newYear(target, existing)
+
+	seen := make(map[int]bool)
+	for c := 1; c <= draw.MaxLevel+1; c++ {
+		seen[c] = true
+	}
+	for _, c := range y.values {
+		seen[c], seen[c+1] = true, true
+	}
+	var candidates []int
+	for c := range seen {
+		candidates = append(candidates, c)
+	}
+	sort.Ints(candidates)
+
+	var best floors
+	var bestT Thresholds
+	found := false
+	bestMisses, bestCost := len(target)+1, 0
+	// days wanted empty that already have contributions are always
+	// missed, so a solution missing only those cannot be beaten on misses
+	unavoidable := y.days[0] - y.count(floors{}, 0, 0)
+	// beaten reports whether even the cheapest lifting under q costs more
+	// than a solution that misses no more days than it has to
+	beaten := func(q [draw.MaxLevel + 1]int) bool {
+		if !found || bestMisses > unavoidable {
+			return false
+		}
+		var f floors
+		for level := 1; level < draw.MaxLevel; level++ {
+			f[level] = min(q[level]+1, q[level+1])
+		}
+		f[1] = max(f[1], 1)
+		f[draw.MaxLevel] = max(q[draw.MaxLevel]+1, top)
+		return y.cost(f) >= bestCost
+	}
+
+	for a, q1 := range candidates {
+		if beaten([draw.MaxLevel + 1]int{0, 0, q1, q1, q1}) {
+			break
+		}
+		for b, q2 := range candidates[a:] {
+			if beaten([draw.MaxLevel + 1]int{0, 0, q1, q2, q2}) {
+				break
+			}
+			for _, q3 := range candidates[a+b:] {
+				q := [draw.MaxLevel + 1]int{0, 0, q1, q2, q3}
+				if beaten(q) {
+					break
+				}
+				for ends := 0; ends < 1<<(draw.MaxLevel-1); ends++ {
+					floor := floors{draw.MaxLevel: max(q3+1, top)}
+					for level := 1; level < draw.MaxLevel; level++ {
+						floor[level] = q[level] + 1
+						if ends&(1<<(level-1)) != 0 {
+							floor[level] = q[level+1]
+						}
+					}
+					floor[1] = max(floor[1], 1)
+
+					cost := y.cost(floor)
+					if cost >= bestCost && bestMisses == unavoidable {
+						continue
+					}
+					t := y.thresholds(floor)
+					misses := y.misses(floor, t)
+					if misses > bestMisses || misses == bestMisses && cost >= bestCost {
+						continue
+					}
+					bestMisses, bestCost = misses, cost
+					best, bestT, found = floor, t, true
+				}
+			}
+		}
+	}
+
+	sol := Solution{Add: make([]int, len(target)), Thresholds: bestT}
+	for i, level := range target {
+		have := at(existing, i)
+		total := max(have, best[level])
+		sol.Add[i] = total - have
+		if bestT.Level(total) != level {
+			sol.Unsatisfiable = append(sol.Unsatisfiable, i)
+		}
+	}
+	return sol
+}
+
+// floors are the fewest contributions a day of each level is lifted to.
+type floors [draw.MaxLevel + 1]int
+
+// year is the existing counts of a year grouped by the level each day is
+// wanted at, tallied by count, so the cost and quartiles of a lifting are
+// found without going through every day.
+type year struct {
+	// le[level][x] is the number of days of level with at most x
+	// existing contributions, and sum[level][x] their total
+	le, sum [draw.MaxLevel + 1][]int
+	days    [draw.MaxLevel + 1]int
+	// active[set][x] is the number of days with contributions and at most
+	// x of them, counting only the levels in set, a bit each from level 1;
+	// the others are lifted above x
+	active [1 << draw.MaxLevel][]int
+	// values are the distinct positive existing counts, sorted
+	values []int
+	// days with contributions once lifted
+	lifted int
+}
+
+func newYear(target, existing []int) *year {
+	y := &year{}
+	busiest := 0
+	seen := make(map[int]bool)
+	for i, level := range target {
+		c := at(existing, i)
+		y.days[level]++
+		busiest = max(busiest, c)
+		if c > 0 && !seen[c] {
+			seen[c] = true
+			y.values = append(y.values, c)
+		}
+		if c > 0 || level > 0 {
+			y.lifted++
+		}
+	}
+	sort.Ints(y.values)
+	for level := range y.le {
+		y.le[level] = make([]int, busiest+1)
+		y.sum[level] = make([]int, busiest+1)
+	}
+	for i, level := range target {
+		c := max(at(existing, i), 0)
+		y.le[level][c]++
+		y.sum[level][c] += c
+	}
+	for level := range y.le {
+		for x := 1; x <= busiest; x++ {
+			y.le[level][x] += y.le[level][x-1]
+			y.sum[level][x] += y.sum[level][x-1]
+		}
+	}
+	for set := range y.active {
+		active := make([]int, busiest+1)
+		for x := range active {
+			// days of level 0 without contributions are not active
+			active[x] = y.le[0][x] - y.le[0][0]
+			for level := 1; level <= draw.MaxLevel; level++ {
+				if set&(1<<(level-1)) != 0 {
+					active[x] += y.le[level][x]
+				}
+			}
+		}
+		y.active[set] = active
+	}
+	return y
+}
+
+// tally looks up a running tally at x, which may be past the busiest day.
+func tally(t []int, x int) int {
+	if x < 0 {
+		return 0
+	}
+	return t[min(x, len(t)-1)]
+}
+
+// cost is the number of commits lifting every day to its floor takes.
+func (y *year) cost(f floors) int {
+	cost := 0
+	for level := 1; level <= draw.MaxLevel; level++ {
+		below := f[level] - 1
+		cost += f[level]*tally(y.le[level], below) - tally(y.sum[level], below)
+	}
+	return cost
+}
+
+// count is the number of days of level that end up with at most x
+// contributions.
+func (y *year) count(f floors, level, x int) int {
+	if x < f[level] {
+		return 0
+	}
+	return tally(y.le[level], x)
+}
+
+// nth is the rth smallest count of the active days. Between floors the
+// same levels are lifted, so it looks for the stretch the count is in and
+// searches that stretch's tally.
+func (y *year) nth(f floors, r int) int {
+	busiest := len(y.le[0]) - 1
+	for lo := 1; ; {
+		// the levels lifted to lo or below, and the next floor above it
+		set, next := 0, -1
+		for level := 1; level <= draw.MaxLevel; level++ {
+			if f[level] <= lo {
+				set |= 1 << (level - 1)
+			} else if next < 0 || f[level] < next {
+				next = f[level]
+			}
+		}
+		active := y.active[set]
+		end := busiest
+		if next >= 0 {
+			end = next - 1
+		}
+		if tally(active, end) >= r {
+			if lo > busiest {
+				return lo
+			}
+			return lo + sort.SearchInts(active[lo:min(end, busiest)+1], r)
+		}
+		if next < 0 {
+			return 0
+		}
+		lo = next
+	}
+}
+
+// thresholds are Quartiles of the lifted year.
+func (y *year) thresholds(f floors) Thresholds {
+	var t Thresholds
+	if y.lifted == 0 {
+		return t
+	}
+	for k := range t {
+		rank := ((k+1)*y.lifted + 3) / 4
+		t[k] = y.nth(f, max(rank, 1))
+	}
+	return t
+}
+
+// misses is the number of days the lifted year shows at another level
+// than wanted under t.
+func (y *year) misses(f floors, t Thresholds) int {
+	// days wanted empty that have contributions always miss
+	misses := y.days[0] - y.count(f, 0, 0)
+	for level := 1; level <= draw.MaxLevel; level++ {
+		shown := y.days[level]
+		if level < draw.MaxLevel {
+			shown = y.count(f, level, t[level-1])
+		}
+		if level > 1 {
+			shown -= y.count(f, level, t[level-2])
+		}
+		misses += y.days[level] - shown
+	}
+	return misses
+}
+
+// Counts holds the contributions already on each day, by date.
+type Counts map[string]int
+
+func key(d time.Time) string {
+	return d.Format("2006-01-02")
+}
+
+func (c Counts) Add(d time.Time, n int) {
+	c[key(d)] += n
+}
+
+func (c Counts) Get(d time.Time) int {
+	return c[key(d)]
+}
+
+// Merge takes the larger count of each day from other. Two sources of the
+// same profile, such as a saved calendar and the repositories behind it,
+// overlap rather than add up.
+func (c Counts) Merge(other Counts) {
+	for day, n := range other {
+		c[day] = max(c[day], n)
+	}
+}
+
+// Options are the settings PlanGrid solves with.
+type Options struct {
+	// Calendar maps the grid to dates, GitHub's if nil.
+	Calendar draw.Calendar
+	// Fill asks for every empty cell to show at level 1.
+	Fill bool
+	// Top is the least commits a level 4 day ends up with, see Solve.
+	Top int
+	// Existing are the contributions already on the graph.
+	Existing Counts
+	// Until is the last day planned, today if zero. A later day plans
+	// commits to be made on days to come.
+	Until time.Time
+}
+
+// Plan is what drawing a grid takes.
+type Plan struct {
+	Foreground []draw.Cell
+	Background []draw.Cell
+	Thresholds Thresholds
+	// Unsatisfiable are the cells that will not show at their Level.
+	Unsatisfiable []draw.Cell
+}
+
+// PlanGrid solves the commits that draw g in year, on top of the existing
+// contributions. Days the calendar does not show, or that are after
+// opts.Until, are left out, as they are by draw.Grid.Cells.
+func PlanGrid(g draw.Grid, year int, opts Options) Plan {
+	cal := opts.Calendar
+	if cal == nil {
+		cal = draw.GitHub{}
+	}
+	until := opts.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	fg := g.CellsUntil(cal, year, 1, until)
+	bg := g.BackgroundCellsUntil(cal, year, 0, until)
+	days := append(append([]draw.Cell{}, bg...), fg...)
+
+	target := make([]int, len(days))
+	existing := make([]int, len(days))
+	for i, c := range days {
+		target[i] = c.Level
+		if i < len(bg) && opts.Fill {
+			target[i] = 1
+		}
+		existing[i] = opts.Existing.Get(c.Date)
+	}
+
+	sol := For(cal).Solve(target, existing, opts.Top)
+	plan := Plan{Thresholds: sol.Thresholds}
+	for i, c := range days {
+		c.Commits = sol.Add[i]
+		if c.Commits == 0 {
+			continue
+		}
+		if i < len(bg) {
+			plan.Background = append(plan.Background, c)
+		} else {
+			plan.Foreground = append(plan.Foreground, c)
+		}
+	}
+	for _, i := range sol.Unsatisfiable {
+		c := days[i]
+		c.Level, c.Commits = target[i], sol.Add[i]
+		plan.Unsatisfiable = append(plan.Unsatisfiable, c)
+	}
+	return plan
+}
+
+// Overlay is how the graph of year will look once plan is drawn over the
+// existing contributions: every day's total, shaded the way cal's
+// platform shades the whole year.
+func Overlay(cal draw.Calendar, year int, existing Counts, plan Plan) (draw.Grid, Thresholds) {
+	if cal == nil {
+		cal = draw.GitHub{}
+	}
+	added := Counts{}
+	for _, c := range append(append([]draw.Cell{}, plan.Background...), plan.Foreground...) {
+		added.Add(c.Date, c.Commits)
+	}
+	var all draw.Grid
+	days := all.BackgroundCells(cal, year, 0)
+	counts := make([]int, len(days))
+	for i, c := range days {
+		counts[i] = existing.Get(c.Date) + added.Get(c.Date)
+	}
+
+	t := For(cal).Thresholds(counts)
+	var g draw.Grid
+	for i, c := range days {
+		g.Set(c.Week, c.Day, t.Level(counts[i]))
+	}
+	return g, t
+}
@@ -0,0 +1,391 @@
+package levels
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+func TestQuartiles(t *testing.T) {
+	tests := []struct {
+		counts []int
+		want   Thresholds
+	}{
+		{nil, Thresholds{}},
+		{[]int{0, 5, 0}, Thresholds{5, 5, 5}},
+		{[]int{1, 2, 3, 4}, Thresholds{1, 2, 3}},
+		{[]int{0, 8, 1, 1, 1, 1, 1, 1, 2}, Thresholds{1, 1, 1}},
+		{[]int{10, 20, 30, 40, 50, 60, 70, 80}, Thresholds{20, 40, 60}},
+	}
+	for _, tt := range tests {
+		if got := Quartiles(tt.counts); got != tt.want {
+			t.Errorf("Quartiles(%v) = %v, want %v", tt.counts, got, tt.want)
+		}
+	}
+
+	if got := Levels(ByQuartile{}, []int{0, 1, 2, 3, 4}); !reflect.DeepEqual(got, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("Levels = %v", got)
+	}
+	// one busy day on an otherwise quiet graph is the lightest green
+	if got := Levels(ByQuartile{}, []int{0, 0, 12}); got[2] != 1 {
+		t.Errorf("a lone day is level %d, want 1", got[2])
+	}
+}
+
+// check verifies that a solution shades every day as wanted, apart from
+// the ones it reports.
+func check(t *testing.T, s Shading, target, existing []int, sol Solution) {
+	t.Helper()
+	totals := make([]int, len(target))
+	for i := range target {
+		totals[i] = sol.Add[i]
+		if i < len(existing) {
+			totals[i] += existing[i]
+		}
+		if sol.Add[i] < 0 {
+			t.Fatalf("day %d: negative commits", i)
+		}
+	}
+	bad := make(map[int]bool)
+	for _, i := range sol.Unsatisfiable {
+		bad[i] = true
+	}
+	for i, level := range Levels(s, totals) {
+		if level != target[i] && !bad[i] {
+			t.Errorf("day %d is level %d, want %d", i, level, target[i])
+		}
+	}
+}
+
+func repeat(level, n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = level
+	}
+	return out
+}
+
+func TestSolve(t *testing.T) {
+	// text on a filled background: the background is the lower quartiles,
+	// so two commits are enough for the text to be darkest
+	target := append(repeat(1, 300), repeat(draw.MaxLevel, 40)...)
+	sol := Solve(target, nil, 0)
+	check(t, ByQuartile{}, target, nil, sol)
+	if len(sol.Unsatisfiable) != 0 || sol.Add[0] != 1 || sol.Add[339] != 2 {
+		t.Errorf("filled text: add %d and %d, %d unsatisfiable", sol.Add[0], sol.Add[339], len(sol.Unsatisfiable))
+	}
+
+	sol = Solve(target, nil, 15)
+	check(t, ByQuartile{}, target, nil, sol)
+	if sol.Add[339] != 15 {
+		t.Errorf("top 15: text gets %d commits", sol.Add[339])
+	}
+
+	// every level in equal parts steps up one commit at a time
+	target = nil
+	for level := 1; level <= draw.MaxLevel; level++ {
+		target = append(target, repeat(level, 10)...)
+	}
+	sol = Solve(target, nil, 0)
+	check(t, ByQuartile{}, target, nil, sol)
+	if len(sol.Unsatisfiable) != 0 || sol.Add[0] != 1 || sol.Add[39] != 4 {
+		t.Errorf("even levels: %v", sol.Add)
+	}
+
+	// level 4 alone is the whole distribution, so it is also level 1
+	target = repeat(draw.MaxLevel, 20)
+	sol = Solve(target, nil, 0)
+	if len(sol.Unsatisfiable) != 20 {
+		t.Errorf("lone level 4: %d unsatisfiable, want 20", len(sol.Unsatisfiable))
+	}
+}
+
+func TestSolveExisting(t *testing.T) {
+	target := append(repeat(1, 30), repeat(draw.MaxLevel, 10)...)
+	target = append(target, 0)
+	existing := make([]int, len(target))
+	existing[3] = 6  // busy day that should be light
+	existing[35] = 9 // already dark
+	existing[40] = 2 // should be empty
+
+	sol := Solve(target, existing, 0)
+	check(t, ByQuartile{}, target, existing, sol)
+	if sol.Add[35] != 0 {
+		t.Errorf("already dark day gets %d more", sol.Add[35])
+	}
+	if !reflect.DeepEqual(sol.Unsatisfiable, []int{40}) {
+		t.Errorf("unsatisfiable = %v, want [40]", sol.Unsatisfiable)
+	}
+
+	// random designs over random activity must at least be consistent
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 20; n++ {
+		target := make([]int, 120)
+		existing := make([]int, 120)
+		for i := range target {
+			target[i] = r.Intn(draw.MaxLevel + 1)
+			if r.Intn(4) == 0 {
+				existing[i] = r.Intn(8)
+			}
+		}
+		check(t, ByQuartile{}, target, existing, Solve(target, existing, r.Intn(10)))
+	}
+}
+
+// randomYear is a design over existing counts with about distinct
+// different values.
+func randomYear(r *rand.Rand, days, distinct int) (target, existing []int) {
+	target = make([]int, days)
+	existing = make([]int, days)
+	for i := range target {
+		if r.Intn(5) == 0 {
+			target[i] = r.Intn(draw.MaxLevel) + 1
+		}
+		if r.Intn(3) > 0 {
+			existing[i] = r.Intn(distinct) + 1
+		}
+	}
+	return target, existing
+}
+
+func TestSolveMatchesSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for n := 0; n < 30; n++ {
+		target, existing := randomYear(r, 60, 1+r.Intn(12))
+		top := r.Intn(10)
+		got, want := Solve(target, existing, top), solveSlow(target, existing, top)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Solve(%v, %v, %d)\n= %+v\nwant %+v", target, existing, top, got, want)
+		}
+	}
+}
+
+// solveSlow is Solve sorting the whole year for every choice of
+// thresholds, as it did before counting by level.
+func solveSlow(target, existing []int, top int) Solution {
+	have := func(i int) int {
+		if i < len(existing) {
+			return existing[i]
+		}
+		return 0
+	}
+	seen := make(map[int]bool)
+	for c := 1; c <= draw.MaxLevel+1; c++ {
+		seen[c] = true
+	}
+	for i := range target {
+		if c := have(i); c > 0 {
+			seen[c], seen[c+1] = true, true
+		}
+	}
+	var candidates []int
+	for c := range seen {
+		candidates = append(candidates, c)
+	}
+	sort.Ints(candidates)
+
+	target = append([]int(nil), target...)
+	for i, level := range target {
+		target[i] = min(max(level, 0), draw.MaxLevel)
+	}
+
+	totals := make([]int, len(target))
+	buf := make([]int, 0, len(target))
+	var best Solution
+	bestMisses, bestCost := len(target)+1, 0
+
+	for a, q1 := range candidates {
+		for b, q2 := range candidates[a:] {
+			for _, q3 := range candidates[a+b:] {
+				q := [draw.MaxLevel + 1]int{0, 0, q1, q2, q3}
+				for ends := 0; ends < 1<<(draw.MaxLevel-1); ends++ {
+					floor := [draw.MaxLevel + 1]int{draw.MaxLevel: max(q3+1, top)}
+					for level := 1; level < draw.MaxLevel; level++ {
+						floor[level] = q[level] + 1
+						if ends&(1<<(level-1)) != 0 {
+							floor[level] = q[level+1]
+						}
+					}
+					floor[1] = max(floor[1], 1)
+
+					cost := 0
+					for i, level := range target {
+						totals[i] = max(have(i), floor[level])
+						cost += totals[i] - have(i)
+					}
+					if cost >= bestCost && bestMisses == 0 {
+						continue
+					}
+
+					t := quartiles(totals, buf)
+					misses := 0
+					for i, level := range target {
+						if t.Level(totals[i]) != level {
+							misses++
+						}
+					}
+					if misses > bestMisses || misses == bestMisses && cost >= bestCost {
+						continue
+					}
+					bestMisses, bestCost = misses, cost
+					best = Solution{Add: make([]int, len(target)), Thresholds: t}
+					for i, level := range target {
+						best.Add[i] = totals[i] - have(i)
+						if t.Level(totals[i]) != level {
+							best.Unsatisfiable = append(best.Unsatisfiable, i)
+						}
+					}
+				}
+			}
+		}
+	}
+	return best
+}
+
+func BenchmarkSolve(b *testing.B) {
+	for _, distinct := range []int{5, 30, 80} {
+		target, existing := randomYear(rand.New(rand.NewSource(3)), 365, distinct)
+		b.Run(fmt.Sprint(distinct), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Solve(target, existing, 15)
+			}
+		})
+	}
+}
+
+func TestPlanGrid(t *testing.T) {
+	year := time.Now().Year() - 1
+	var g draw.Grid
+	g.Set(10, 3, draw.MaxLevel)
+	g.Set(11, 3, 2)
+
+	// with most of the year at level 1 every quartile is 1, so there is
+	// no level 2
+	plan := PlanGrid(g, year, Options{Fill: true})
+	if len(plan.Unsatisfiable) != 1 || plan.Unsatisfiable[0].Week != 11 {
+		t.Fatalf("unsatisfiable = %+v, want the level 2 cell", plan.Unsatisfiable)
+	}
+	g.Set(11, 3, draw.MaxLevel)
+	plan = PlanGrid(g, year, Options{Fill: true})
+	if len(plan.Unsatisfiable) != 0 {
+		t.Fatalf("%d cells unsatisfiable", len(plan.Unsatisfiable))
+	}
+	if len(plan.Foreground) != 2 || len(plan.Background) < 360 {
+		t.Fatalf("%d foreground and %d background cells", len(plan.Foreground), len(plan.Background))
+	}
+
+	// a busy day in the background is only light if the rest of the
+	// background is as busy
+	existing := Counts{}
+	busy := plan.Background[0].Date
+	existing.Add(busy, 30)
+	plan = PlanGrid(g, year, Options{Fill: true, Existing: existing})
+	if len(plan.Unsatisfiable) != 0 || plan.Background[1].Commits != 30 {
+		t.Errorf("busy background day: %d unsatisfiable, %d commits on a quiet day",
+			len(plan.Unsatisfiable), plan.Background[1].Commits)
+	}
+
+	// commits cannot be taken away from a day meant to be empty, and two
+	// cells cannot be above the third quartile of three
+	plan = PlanGrid(g, year, Options{Existing: existing})
+	if len(plan.Unsatisfiable) != 3 || !plan.Unsatisfiable[0].Date.Equal(busy) || plan.Unsatisfiable[0].Level != 0 {
+		t.Errorf("busy empty day: unsatisfiable = %+v", plan.Unsatisfiable)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	target := []int{0, 1, 2, 3, 4, 4, 2}
+	existing := []int{0, 3, 0, 25, 0, 40, 12}
+	sol := GitLabBuckets.Solve(target, existing, 35)
+	check(t, GitLabBuckets, target, existing, sol)
+	if want := []int{0, 0, 10, 0, 35, 0, 0}; !reflect.DeepEqual(sol.Add, want) {
+		t.Errorf("Add = %v, want %v", sol.Add, want)
+	}
+	if len(sol.Unsatisfiable) != 0 {
+		t.Errorf("unsatisfiable = %v", sol.Unsatisfiable)
+	}
+
+	// a day cannot be taken down a bucket
+	sol = GitLabBuckets.Solve([]int{1, 0}, []int{15, 2}, 0)
+	if !reflect.DeepEqual(sol.Unsatisfiable, []int{0, 1}) {
+		t.Errorf("unsatisfiable = %v, want both days", sol.Unsatisfiable)
+	}
+}
+
+func TestByMax(t *testing.T) {
+	if got := (ByMax{}).Thresholds([]int{0, 3, 8}); got != (Thresholds{2, 4, 6}) {
+		t.Errorf("Thresholds = %v", got)
+	}
+
+	target := []int{0, 1, 2, 3, 4, 1, 0}
+	sol := ByMax{}.Solve(target, nil, 0)
+	check(t, ByMax{}, target, nil, sol)
+	if want := []int{0, 1, 2, 3, 4, 1, 0}; !reflect.DeepEqual(sol.Add, want) {
+		t.Errorf("Add = %v, want %v", sol.Add, want)
+	}
+
+	// a busy existing day raises the bar for everything else
+	existing := []int{0, 0, 0, 0, 0, 0, 20}
+	target[6] = draw.MaxLevel
+	sol = ByMax{}.Solve(target, existing, 0)
+	check(t, ByMax{}, target, existing, sol)
+	if len(sol.Unsatisfiable) != 0 || sol.Add[4] != 20 {
+		t.Errorf("busy day: Add = %v, unsatisfiable = %v", sol.Add, sol.Unsatisfiable)
+	}
+}
+
+func TestPlanGridCalendars(t *testing.T) {
+	var g draw.Grid
+	g.Set(40, 3, draw.MaxLevel)
+	g.Set(41, 3, 2)
+	year := time.Now().Year()
+	for _, cal := range []draw.Calendar{draw.GitLab{}, draw.Gitea{}} {
+		plan := PlanGrid(g, year, Options{Calendar: cal, Fill: true, Top: 12})
+		if len(plan.Unsatisfiable) != 0 {
+			t.Errorf("%s: %d cells unsatisfiable", cal.Name(), len(plan.Unsatisfiable))
+		}
+		if len(plan.Foreground) != 2 || plan.Foreground[0].Commits < plan.Foreground[1].Commits {
+			t.Errorf("%s: foreground = %+v", cal.Name(), plan.Foreground)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	year := time.Now().Year() - 1
+	var g draw.Grid
+	g.Set(20, 2, draw.MaxLevel)
+	g.Set(21, 2, draw.MaxLevel)
+
+	existing := Counts{}
+	start := draw.Start(draw.GitHub{}, year)
+	for week := 30; week < 40; week++ {
+		existing.Add(start.AddDate(0, 0, week*7+4), week-29)
+	}
+	plan := PlanGrid(g, year, Options{Existing: existing, Top: 5})
+	got, _ := Overlay(nil, year, existing, plan)
+	if got.At(20, 2) != draw.MaxLevel || got.At(21, 2) != draw.MaxLevel {
+		t.Errorf("design shows at %d and %d", got.At(20, 2), got.At(21, 2))
+	}
+	if got.At(30, 4) != 1 || got.At(0, 0) != 0 {
+		t.Errorf("quietest existing day at %d, empty day at %d", got.At(30, 4), got.At(0, 0))
+	}
+
+	// the design alone, without what is already there, is a different year
+	alone, _ := Overlay(nil, year, nil, plan)
+	if alone.At(30, 4) != 0 {
+		t.Error("existing day shown without existing counts")
+	}
+
+	merged := Counts{}
+	merged.Add(start, 3)
+	merged.Merge(Counts{key(start): 5})
+	merged.Merge(Counts{key(start): 2})
+	if merged.Get(start) != 5 {
+		t.Errorf("merged count %d, want the larger 5", merged.Get(start))
+	}
+}
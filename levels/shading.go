@@ -0,0 +1,124 @@
+package levels
+
+import "github.com/1etu/gitdraw/draw"
+
+// Shading is how a platform turns a year of daily counts into levels.
+type Shading interface {
+	// Thresholds are the highest counts shown at levels 1 to 3.
+	Thresholds(counts []int) Thresholds
+	// Solve finds the fewest commits to add to each day so that day i
+	// shows at level target[i], with at least top commits in all on level
+	// 4 days.
+	Solve(target, existing []int, top int) Solution
+}
+
+// ByQuartile is GitHub's shading, see the package comment and Solve.
+type ByQuartile struct{}
+
+func (ByQuartile) Thresholds(counts []int) Thresholds { return Quartiles(counts) }
+
+func (ByQuartile) Solve(target, existing []int, top int) Solution {
+	return Solve(target, existing, top)
+}
+
+// ByBucket shades with fixed thresholds, whatever else is in the year.
+type ByBucket Thresholds
+
+// GitLabBuckets are GitLab's: 1-9, 10-19, 20-29 and 30 or more.
+var GitLabBuckets = ByBucket{9, 19, 29}
+
+func (b ByBucket) Thresholds([]int) Thresholds { return Thresholds(b) }
+
+// Solve lifts every day to the bottom of its bucket. Days are independent,
+// so only days that already have too many contributions are missed.
+func (b ByBucket) Solve(target, existing []int, top int) Solution {
+	t := Thresholds(b)
+	floor := [draw.MaxLevel + 1]int{0, 1, t[0] + 1, t[1] + 1, max(t[2]+1, top)}
+	sol := Solution{Add: make([]int, len(target)), Thresholds: t}
+	for i, level := range target {
+		level = min(max(level, 0), draw.MaxLevel)
+		have := at(existing, i)
+		total := max(have, floor[level])
+		sol.Add[i] = total - have
+		if t.Level(total) != level {
+			sol.Unsatisfiable = append(sol.Unsatisfiable, i)
+		}
+	}
+	return sol
+}
+
+// ByMax shades relative to the busiest day of the year, in equal steps:
+// a day is level ceil(4 * count / busiest). This is how Gitea's heatmap
+// picks its colours.
+type ByMax struct{}
+
+func (ByMax) Thresholds(counts []int) Thresholds {
+	busiest := 0
+	for _, c := range counts {
+		busiest = max(busiest, c)
+	}
+	return Thresholds{busiest / 4, busiest / 2, busiest * 3 / 4}
+}
+
+// Solve tries each busiest count from the least that can hold the design
+// upwards, lifts the days to the bottom of their step under it, and keeps
+// the cheapest choice that gets the most days right.
+func (m ByMax) Solve(target, existing []int, top int) Solution {
+	busiest := 0
+	for i := range target {
+		busiest = max(busiest, at(existing, i))
+	}
+	from := max(busiest, top, draw.MaxLevel)
+
+	totals := make([]int, len(target))
+	var best Solution
+	bestMisses, bestCost := len(target)+1, 0
+	for peak := from; peak <= max(from, 4*busiest+draw.MaxLevel); peak++ {
+		t := Thresholds{peak / 4, peak / 2, peak * 3 / 4}
+		floor := [draw.MaxLevel + 1]int{0, 1, t[0] + 1, t[1] + 1, peak}
+		cost := 0
+		for i, level := range target {
+			level = min(max(level, 0), draw.MaxLevel)
+			totals[i] = max(at(existing, i), floor[level])
+			cost += totals[i] - at(existing, i)
+		}
+
+		t = m.Thresholds(totals)
+		misses := 0
+		for i, level := range target {
+			if t.Level(totals[i]) != min(max(level, 0), draw.MaxLevel) {
+				misses++
+			}
+		}
+		if misses > bestMisses || misses == bestMisses && cost >= bestCost {
+			continue
+		}
+		bestMisses, bestCost = misses, cost
+		best = Solution{Add: make([]int, len(target)), Thresholds: t}
+		for i, level := range target {
+			best.Add[i] = totals[i] - at(existing, i)
+			if t.Level(totals[i]) != min(max(level, 0), draw.MaxLevel) {
+				best.Unsatisfiable = append(best.Unsatisfiable, i)
+			}
+		}
+	}
+	return best
+}
+
+// For returns the shading of cal's platform.
+func For(cal draw.Calendar) Shading {
+	switch cal.(type) {
+	case draw.GitLab:
+		return GitLabBuckets
+	case draw.Gitea:
+		return ByMax{}
+	}
+	return ByQuartile{}
+}
+
+func at(counts []int, i int) int {
+	if i < len(counts) {
+		return counts[i]
+	}
+	return 0
+}
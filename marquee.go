@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/config"
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/marquee"
+)
+
+const marqueeUsage = "usage: gitdraw marquee --text <message> --start <yyyy-mm-dd> [options] <repo>"
+
+// runMarquee is `gitdraw marquee`: commit what a scrolling message needs
+// up to today on top of an existing repository. It is meant to run daily
+// and adds nothing when it is up to date.
+func runMarquee(args []string) {
+	setupOutput(outputText)
+
+	var start, today, author, configPath, force string
+	var flags config.Config
+	var style git.Style
+	var push git.PushOptions
+	var m marquee.Marquee
+
+	fs := flag.NewFlagSet("marquee", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&flags.Text, "text", "", "message to scroll")
+	fs.StringVar(&start, "start", "", "date of the first column, yyyy-mm-dd")
+	fs.StringVar(&today, "today", "", "commit up to this date instead of today")
+	fs.BoolVar(&m.Loop, "loop", false, "start the message over once it has scrolled in")
+	fs.BoolVar(&m.Fill, "fill", false, "draw the days between letters at level 1")
+	fs.IntVar(&flags.Intensity, "intensity", 0, "least commits on a level 4 day")
+	fs.StringVar(&author, "author", "", `commit author, "Name" or "Name <email>"`)
+	fs.StringVar(&flags.Timezone, "timezone", "", "timezone to date commits in")
+	fs.StringVar(&flags.Calendar, "calendar", "", "platform calendar: "+strings.Join(draw.Calendars, ", "))
+	fs.StringVar(&flags.WeekStart, "week-start", "", "weekday of the top row")
+	fs.StringVar(&configPath, "config", "", "config file")
+	fs.StringVar(&style.Message, "message", git.DefaultMessage, "commit message template")
+	fs.StringVar(&style.Content, "content", git.ContentOverwrite, "content strategy")
+	fs.StringVar(&push.Branch, "branch", "", "branch to commit to (default: current)")
+	fs.StringVar(&push.Remote, "remote-name", "", "push to this remote afterwards")
+	fs.StringVar(&force, "push-mode", "none", "none, lease or force")
+	dryRun := fs.Bool("dry-run", false, "show what is due without committing")
+
+	if err := fs.Parse(args); err != nil {
+		exit(err.Error())
+	}
+	if fs.NArg() != 1 {
+		exit(marqueeUsage)
+	}
+	if author != "" {
+		if err := flags.Set("author", author); err != nil {
+			exit(err.Error())
+		}
+	}
+	if flags.Timezone != "" {
+		if err := flags.Set("timezone", flags.Timezone); err != nil {
+			exit(err.Error())
+		}
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		exit(err.Error())
+	}
+	cfg = cfg.Override(flags)
+	if !git.ValidContent(style.Content) {
+		exit("unknown content strategy: " + style.Content)
+	}
+	if push.Force, err = git.ParseForce(force); err != nil {
+		exit(err.Error())
+	}
+
+	if m.Calendar, err = draw.ParseCalendar(cfg.Calendar); err != nil {
+		exit(err.Error())
+	}
+	if cfg.WeekStart != "" {
+		first, err := draw.ParseWeekday(cfg.WeekStart)
+		if err != nil {
+			exit(err.Error())
+		}
+		m.Calendar = draw.StartWeek(m.Calendar, first)
+	}
+	if cfg.Text == "" || start == "" {
+		exit(marqueeUsage)
+	}
+	if m.Start, err = time.Parse("2006-01-02", start); err != nil {
+		exit("bad start date: " + start)
+	}
+	// the message is expanded as of the start, so it is the same every run
+	m.Message = strings.ToUpper(expandText(cfg.Text, m.Start.Year(), m.Start))
+	m.Top = cfg.Intensity
+
+	now := time.Now()
+	if loc := cfg.Location(); loc != nil {
+		now = now.In(loc)
+	}
+	if today != "" {
+		if now, err = time.Parse("2006-01-02", today); err != nil {
+			exit("bad date: " + today)
+		}
+	}
+
+	repo, err := git.Open(fs.Arg(0))
+	if err != nil {
+		exit(err.Error())
+	}
+	repo.Author, repo.Email = cfg.Author, cfg.Email
+	repo.Location = cfg.Location()
+	repo.Style = style
+	// the start tells this marquee's commits from another's on the branch
+	id := m.Start.Format("2006-01-02")
+	repo.Trailers = []string{marquee.Trailer + ": " + id}
+
+	branch := push.Branch
+	if branch == "" {
+		if branch, err = repo.CurrentBranch(); err != nil {
+			exit(err.Error())
+		}
+	}
+
+	done, err := repo.TrailerCounts(branch, marquee.Trailer, id)
+	if err != nil {
+		exit(err.Error())
+	}
+	due, err := m.Due(now, done)
+	if err != nil {
+		exit(err.Error())
+	}
+
+	commits := 0
+	for _, c := range due {
+		commits += c.Commits
+	}
+	fmt.Fprintln(console)
+	info("marquee", fmt.Sprintf("%q, %d weeks from %s", m.Message, m.Width(), m.Start.Format("Jan 2, 2006")))
+	if missed, _ := m.Unsatisfiable(); missed > 0 {
+		warn(fmt.Sprintf("%d cells cannot show at their level on %s", missed, m.Calendar.Name()))
+	}
+	if commits == 0 {
+		success("up to date as of " + now.Format("Jan 2, 2006"))
+		fmt.Fprintln(console)
+		return
+	}
+	info("due", fmt.Sprintf("%d commits on %d days, up to %s", commits, len(due), now.Format("Jan 2, 2006")))
+	if *dryRun {
+		for _, c := range due {
+			fmt.Fprintf(console, "  %s%s  %d%s\n", dim, c.Date.Format("2006-01-02 Mon"), c.Commits, reset)
+		}
+		fmt.Fprintln(console)
+		return
+	}
+
+	if err := repo.Append(branch, due, nil); err != nil {
+		exit(err.Error())
+	}
+	success(fmt.Sprintf("%d commits added to %s", commits, branch))
+
+	if push.Remote != "" {
+		push.Local, push.Branch = branch, branch
+		if err := repo.Push(push); err != nil {
+			exit(err.Error())
+		}
+		success("pushed to " + push.Remote)
+	}
+	fmt.Fprintln(console)
+}
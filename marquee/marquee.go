@@ -0,0 +1,139 @@
+// Package marquee scrolls a message across the contribution graph. The
+// message is laid out as one long strip and its k-th column is drawn in
+// the k-th week after the start, so each week the graph moves on, the
+// message moves one column to the left. Only days that have come are
+// drawn, so a marquee is kept going by committing what is due every day,
+// from cron or a timer.
+package marquee
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/levels"
+)
+
+// Trailer marks the commits of a marquee, with its start date as the
+// value, so later runs know what is already drawn.
+const Trailer = "Gitdraw-Marquee"
+
+// Marquee is a message scrolling from a start date.
+type Marquee struct {
+	// Message is drawn with draw.Strip, so it may use sprites.
+	Message string
+	// Start is a day in the week of the first column.
+	Start time.Time
+	// Calendar sets the top row's weekday and the shading, GitHub's if
+	// nil.
+	Calendar draw.Calendar
+	// Loop starts the message over once it has all scrolled in.
+	Loop bool
+	// Fill draws the days between the letters at level 1, which GitHub
+	// needs to shade letters darker than that.
+	Fill bool
+	// Top is the least commits a level 4 day gets, see levels.Solve.
+	Top int
+}
+
+// solved is a marquee's strip with the commits every cell takes.
+type solved struct {
+	cols    [][draw.Rows]int
+	commits [][draw.Rows]int
+	missed  int
+}
+
+func (m Marquee) calendar() draw.Calendar {
+	if m.Calendar == nil {
+		return draw.GitHub{}
+	}
+	return m.Calendar
+}
+
+// solve shades the strip on its own, as if it were the whole year. A
+// year of the graph is a stretch of the strip, so it shades about the same.
+func (m Marquee) solve() (solved, error) {
+	cols := draw.Strip(m.Message)
+	if len(cols) == 0 {
+		return solved{}, fmt.Errorf("marquee message is empty")
+	}
+
+	target := make([]int, 0, len(cols)*draw.Rows)
+	for _, col := range cols {
+		for _, level := range col {
+			if level == 0 && m.Fill {
+				level = 1
+			}
+			target = append(target, level)
+		}
+	}
+	sol := levels.For(m.calendar()).Solve(target, nil, m.Top)
+
+	s := solved{cols: cols, commits: make([][draw.Rows]int, len(cols)), missed: len(sol.Unsatisfiable)}
+	for i, n := range sol.Add {
+		s.commits[i/draw.Rows][i%draw.Rows] = n
+	}
+	return s, nil
+}
+
+// Width is the number of weeks the message takes to scroll in.
+func (m Marquee) Width() int {
+	return len(draw.Strip(m.Message))
+}
+
+// Unsatisfiable is the number of cells of the message that will not
+// show at their level, because of how the platform shades.
+func (m Marquee) Unsatisfiable() (int, error) {
+	s, err := m.solve()
+	return s.missed, err
+}
+
+// first is the top day of the first column.
+func (m Marquee) first() time.Time {
+	start := time.Date(m.Start.Year(), m.Start.Month(), m.Start.Day(), 12, 0, 0, 0, time.UTC)
+	back := (int(start.Weekday()) - int(m.calendar().FirstDay()) + 7) % 7
+	return start.AddDate(0, 0, -back)
+}
+
+// Cells are the days of the marquee up to and including the day of
+// until, with every commit they take. Cell.Week is the column of the
+// strip, counted from the start over every loop.
+func (m Marquee) Cells(until time.Time) ([]draw.Cell, error) {
+	s, err := m.solve()
+	if err != nil {
+		return nil, err
+	}
+	last := time.Date(until.Year(), until.Month(), until.Day(), 12, 0, 0, 0, time.UTC)
+
+	var cells []draw.Cell
+	for d, n := m.first(), 0; !d.After(last); d, n = d.AddDate(0, 0, 1), n+1 {
+		week, day := n/draw.Rows, n%draw.Rows
+		col := week
+		if m.Loop {
+			col %= len(s.cols)
+		} else if col >= len(s.cols) {
+			break
+		}
+		if commits := s.commits[col][day]; commits > 0 {
+			cells = append(cells, draw.Cell{Week: week, Day: day, Level: s.cols[col][day], Date: d, Commits: commits})
+		}
+	}
+	return cells, nil
+}
+
+// Due is what is left to commit up to until, given the marquee's commits
+// already made on each day. Running it again once those are made gives
+// nothing.
+func (m Marquee) Due(until time.Time, done levels.Counts) ([]draw.Cell, error) {
+	cells, err := m.Cells(until)
+	if err != nil {
+		return nil, err
+	}
+	var due []draw.Cell
+	for _, c := range cells {
+		if c.Commits -= done.Get(c.Date); c.Commits > 0 {
+			due = append(due, c)
+		}
+	}
+	return due, nil
+}
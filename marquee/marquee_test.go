@@ -0,0 +1,87 @@
+package marquee
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/levels"
+)
+
+func day(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestCells(t *testing.T) {
+	// a Wednesday, so the first column starts on Sunday the 4th
+	m := Marquee{Message: "HI", Start: day("2026-01-07"), Fill: true}
+	cols := draw.Strip("HI")
+
+	cells, err := m.Cells(day("2026-01-03"))
+	if err != nil || len(cells) != 0 {
+		t.Errorf("cells before the start: %v, %v", cells, err)
+	}
+
+	cells, err = m.Cells(day("2026-01-10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != draw.Rows || !cells[0].Date.Equal(day("2026-01-04").Add(12*time.Hour)) {
+		t.Fatalf("first week has %d cells from %v", len(cells), cells[0].Date)
+	}
+	for _, c := range cells {
+		if c.Week != 0 || c.Level != cols[0][c.Day] {
+			t.Errorf("cell %+v is not the first column", c)
+		}
+	}
+
+	// without loop, nothing is drawn once the message has scrolled in
+	all, _ := m.Cells(day("2027-06-01"))
+	if last := all[len(all)-1]; last.Week != len(cols)-1 {
+		t.Errorf("last cell is in week %d, want %d", last.Week, len(cols)-1)
+	}
+	m.Loop = true
+	looped, _ := m.Cells(day("2027-06-01"))
+	if len(looped) <= len(all) || looped[len(all)].Level != cols[0][0] {
+		t.Error("the message did not start over")
+	}
+}
+
+func TestDue(t *testing.T) {
+	m := Marquee{Message: "I", Start: day("2026-01-04"), Top: 3}
+	today := day("2026-01-20")
+
+	due, err := m.Due(today, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) == 0 {
+		t.Fatal("nothing due")
+	}
+
+	done := levels.Counts{}
+	for _, c := range due {
+		done.Add(c.Date, c.Commits)
+	}
+	if again, _ := m.Due(today, done); len(again) != 0 {
+		t.Errorf("%d cells due again on the same day", len(again))
+	}
+
+	// a day half done only needs the rest
+	done.Add(due[0].Date, -1)
+	if again, _ := m.Due(today, done); len(again) != 1 || again[0].Commits != 1 {
+		t.Errorf("due after a partial run: %+v", again)
+	}
+
+	if _, err := (Marquee{Start: today}).Due(today, nil); err == nil {
+		t.Error("empty message accepted")
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	m := Marquee{Message: "I", Start: day("2026-01-07"), Calendar: draw.StartWeek(draw.GitHub{}, time.Monday)}
+	if first := m.first(); first.Weekday() != time.Monday || first.Day() != 5 {
+		t.Errorf("first day is %v", first)
+	}
+}
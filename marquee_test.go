@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/marquee"
+)
+
+func TestTwoMarquees(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "me")
+	t.Setenv("GIT_AUTHOR_EMAIL", "me@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "me")
+	t.Setenv("GIT_COMMITTER_EMAIL", "me@example.com")
+	// runMarquee points the console at stdout
+	old := console
+	defer func() { console = old }()
+
+	scroll := func(dir, start string) {
+		runMarquee([]string{"--text", "HI", "--start", start, "--today", "2025-03-01", "--config", "/dev/null", dir})
+	}
+	counts := func(repo *git.Repo, start string) int {
+		branch, err := repo.CurrentBranch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		done, err := repo.TrailerCounts(branch, marquee.Trailer, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0
+		for _, n := range done {
+			total += n
+		}
+		return total
+	}
+
+	shared, err := git.Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alone, err := git.Init(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second marquee starts while the first is still scrolling, so
+	// their days overlap; its commits are its own either way
+	scroll(shared.Path, "2025-01-05")
+	scroll(shared.Path, "2025-02-02")
+	scroll(alone.Path, "2025-02-02")
+	if got, want := counts(shared, "2025-02-02"), counts(alone, "2025-02-02"); got != want || want == 0 {
+		t.Errorf("second marquee made %d commits next to the first, %d alone", got, want)
+	}
+}
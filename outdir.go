@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+)
+
+// prepareOutputDir makes sure path can be used for a new repository. Only
+// empty directories and earlier gitdraw repositories may be deleted
+// without force; anything can be moved aside to a backup instead.
+func prepareOutputDir(path string, force bool) bool {
+	kind, err := git.Inspect(path)
+	if err != nil {
+		exit(err.Error())
+	}
+	if kind == git.DirMissing || kind == git.DirEmpty {
+		return true
+	}
+
+	fmt.Fprintln(console)
+	warn(fmt.Sprintf("%s already exists (%s)", path, kind))
+
+	if confirm("Move it to a backup") {
+		backup, err := git.Backup(path)
+		if err != nil {
+			exit(err.Error())
+		}
+		info("backup", backup)
+		return true
+	}
+
+	if kind != git.DirGitdraw && !force {
+		warn("refusing to delete it, rerun with --force to allow")
+		fmt.Fprintln(console, dim+"Cancelled."+reset)
+		return false
+	}
+
+	if !confirm("Delete it") {
+		fmt.Fprintln(console, dim+"Cancelled."+reset)
+		return false
+	}
+	if err := os.RemoveAll(path); err != nil {
+		exit(err.Error())
+	}
+	return true
+}
+
+// resumable looks for an earlier run of the same plan in repo.Path and
+// returns its checkpoint if the user wants to pick it up again.
+func resumable(repo *git.Repo, cells []draw.Cell) *git.Checkpoint {
+	cp, err := repo.ReadCheckpoint()
+	if err != nil || cp == nil || cp.Plan != repo.PlanHash(cells) {
+		return nil
+	}
+
+	fmt.Fprintln(console)
+	prompt := "Resume it"
+	switch {
+	case cp.Pushed:
+		info("found", "this design, already generated and pushed")
+		prompt = "Reuse it"
+	case cp.Complete():
+		info("found", "this design, generated but not pushed")
+		prompt = "Reuse it"
+	default:
+		info("found", fmt.Sprintf("an interrupted run of this design (%d/%d commits)", cp.Done, cp.Total))
+	}
+
+	if !confirm(prompt) {
+		return nil
+	}
+	return cp
+}
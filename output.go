@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/levels"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	// events receives newline delimited JSON with --output json, and is
+	// nil otherwise.
+	events *json.Encoder
+
+	// interactive is false when stdout is not a terminal, which turns off
+	// the spinner, the progress bar and screen clearing.
+	interactive = true
+
+	// color is false when escapes are turned off.
+	color = true
+
+	// unattended is set by --yes: every question takes its default
+	// answer without reading stdin, so a run can be driven by flags alone.
+	unattended bool
+
+	// console receives everything meant for people: stdout, or stderr
+	// when stdout carries json events.
+	console io.Writer = os.Stdout
+
+	// result is reported as the final event of a run.
+	result = resultEvent{Event: "result"}
+)
+
+type planEvent struct {
+	Event            string     `json:"event"`
+	Pixels           int        `json:"pixels"`
+	BackgroundPixels int        `json:"background_pixels"`
+	TotalCommits     int        `json:"total_commits"`
+	Year             int        `json:"year"`
+	From             string     `json:"from,omitempty"`
+	To               string     `json:"to,omitempty"`
+	Budget           git.Budget `json:"budget"`
+	Thresholds       [3]int     `json:"thresholds"`
+	Unsatisfiable    int        `json:"unsatisfiable,omitempty"`
+}
+
+type progressEvent struct {
+	Event string `json:"event"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+type messageEvent struct {
+	Event   string `json:"event"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+type resultEvent struct {
+	Event   string `json:"event"`
+	OK      bool   `json:"ok"`
+	Repo    string `json:"repo,omitempty"`
+	Commits int    `json:"commits"`
+	Remote  string `json:"remote,omitempty"`
+	Pushed  bool   `json:"pushed"`
+	Error   string `json:"error,omitempty"`
+	// Shards are the repositories of a --shard run.
+	Shards []shardEvent `json:"shards,omitempty"`
+}
+
+type shardEvent struct {
+	Name    string `json:"name"`
+	Repo    string `json:"repo"`
+	Commits int    `json:"commits"`
+	Remote  string `json:"remote,omitempty"`
+	Pushed  bool   `json:"pushed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// setupOutput switches to the given --output mode. In json mode stdout is
+// kept for events and the console is stderr. Colours are dropped when
+// NO_COLOR is set or the console is not a terminal.
+func setupOutput(mode string) error {
+	out := os.Stdout
+	switch mode {
+	case "", outputText:
+	case outputJSON:
+		events = json.NewEncoder(os.Stdout)
+		out = os.Stderr
+	default:
+		return fmt.Errorf("unknown output mode: %s", mode)
+	}
+
+	console = out
+	interactive = isTerminal(out)
+	if !interactive || os.Getenv("NO_COLOR") != "" {
+		reset, dim, bold, green, yellow, cyan = "", "", "", "", "", ""
+		color = false
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func emit(v any) {
+	if events != nil {
+		events.Encode(v)
+	}
+}
+
+func newPlan(p levels.Plan, year int, budget git.Budget) planEvent {
+	plan := planEvent{
+		Event:            "plan",
+		Pixels:           len(p.Foreground),
+		BackgroundPixels: len(p.Background),
+		TotalCommits:     budget.Commits,
+		Year:             year,
+		Budget:           budget,
+		Thresholds:       p.Thresholds,
+		Unsatisfiable:    len(p.Unsatisfiable),
+	}
+	for _, cells := range [][]draw.Cell{p.Foreground, p.Background} {
+		for _, c := range cells {
+			day := c.Date.Format("2006-01-02")
+			if plan.From == "" || day < plan.From {
+				plan.From = day
+			}
+			if day > plan.To {
+				plan.To = day
+			}
+		}
+	}
+	return plan
+}
+
+func emitPlan(p levels.Plan, year int, budget git.Budget) {
+	emit(newPlan(p, year, budget))
+}
+
+// progressReporter returns a FastImportCells callback that emits a
+// progress event for every percent done.
+func progressReporter() func(int, int) {
+	last := -1
+	return func(done, total int) {
+		if total == 0 {
+			return
+		}
+		if pct := done * 100 / total; pct != last || done == total {
+			last = pct
+			emit(progressEvent{Event: "progress", Done: done, Total: total})
+		}
+	}
+}
+
+func emitStep(msg string, err error) {
+	step := messageEvent{Event: "step", Message: msg}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	emit(step)
+}
+
+// finish emits the result of the run. An empty result.Error means it
+// succeeded.
+func finish() {
+	result.OK = result.Error == ""
+	if result.Repo != "" {
+		if abs, err := filepath.Abs(result.Repo); err == nil {
+			result.Repo = abs
+		}
+	}
+	emit(result)
+}
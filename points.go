@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+// Point is a painted cell in the points JSON shared by the GUI and the
+// editor.
+type Point struct {
+	Week  int    `json:"week"`
+	Day   int    `json:"day"`
+	Level int    `json:"level"`
+	Layer string `json:"layer,omitempty"`
+}
+
+func gridToPoints(grid draw.Grid) string {
+	return layersToPoints([]draw.Layer{{Grid: grid}})
+}
+
+func layersToPoints(layers []draw.Layer) string {
+	points := []Point{}
+	for _, l := range layers {
+		for _, p := range l.Grid.Points() {
+			points = append(points, Point{Week: p.Week, Day: p.Day, Level: p.Level, Layer: l.Name})
+		}
+	}
+
+	jsonData, _ := json.Marshal(points)
+	return string(jsonData)
+}
+
+// Drawing is the points JSON shared by the GUI, the editor and the HTTP
+// API. It is either a list of points, or an object that also lists the
+// layers bottom to top with their settings, so their order and empty
+// layers survive a round trip:
+//
+//	{"layers": [{"name": "sky", "blend": "max", "levels": [0, 1, 1, 2, 2]}, {"name": "text"}],
+//	 "points": [{"week": 3, "day": 1, "level": 4, "layer": "text"}]}
+type Drawing struct {
+	Layers []draw.Layer `json:"layers,omitempty"`
+	Points []Point      `json:"points"`
+}
+
+func (d *Drawing) UnmarshalJSON(data []byte) error {
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		*d = Drawing{}
+		return json.Unmarshal(data, &d.Points)
+	}
+	type drawing Drawing
+	if err := json.Unmarshal(data, (*drawing)(d)); err != nil {
+		return err
+	}
+	for i, l := range d.Layers {
+		b, err := draw.ParseBlend(string(l.Blend))
+		if err != nil {
+			return fmt.Errorf("layer %q: %v", l.Name, err)
+		}
+		d.Layers[i].Blend = b
+		if l.Levels != nil && len(l.Levels) != draw.MaxLevel+1 {
+			return fmt.Errorf("layer %q: level mapping needs %d values, got %d", l.Name, draw.MaxLevel+1, len(l.Levels))
+		}
+	}
+	return nil
+}
+
+// layers groups the points by layer. A drawing that lists its layers
+// stacks them that way, otherwise stack gives the order and settings.
+func (d Drawing) layers(stack []draw.Layer) []draw.Layer {
+	if d.Layers != nil {
+		stack = d.Layers
+	}
+	return pointsToLayers(d.Points, stack)
+}
+
+// readPoints reads a points JSON file, as saved by the GUI or the editor,
+// and flattens its layers.
+func readPoints(name string) (draw.Grid, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return draw.Grid{}, err
+	}
+	var d Drawing
+	if err := json.Unmarshal(data, &d); err != nil {
+		return draw.Grid{}, fmt.Errorf("%s: %v", name, err)
+	}
+	return draw.Flatten(d.layers(nil)...), nil
+}
+
+// pointsToLayers groups points by layer, stacking the layers of stack in
+// its order and with its settings, then any others in the order they
+// first appear.
+func pointsToLayers(points []Point, stack []draw.Layer) []draw.Layer {
+	index := make(map[string]int)
+	var layers []draw.Layer
+	add := func(l draw.Layer) int {
+		l.Grid = draw.Grid{}
+		index[l.Name] = len(layers)
+		layers = append(layers, l)
+		return len(layers) - 1
+	}
+	for _, l := range stack {
+		if _, ok := index[l.Name]; !ok {
+			add(l)
+		}
+	}
+	for _, p := range points {
+		i, ok := index[p.Layer]
+		if !ok {
+			i = add(draw.Layer{Name: p.Layer})
+		}
+		// a listed point is painted, even without a level
+		layers[i].Grid.Set(p.Week, p.Day, min(max(p.Level, 1), draw.MaxLevel))
+	}
+	return layers
+}
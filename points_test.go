@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+func TestDrawing(t *testing.T) {
+	// a listed layer stacks where it is listed, even when it is empty or
+	// its points come later
+	var d Drawing
+	err := json.Unmarshal([]byte(`{
+		"layers": [{"name": "sky", "levels": [0, 1, 1, 1, 1]}, {"name": "empty"}, {"name": "text", "blend": "replace"}],
+		"points": [{"week": 1, "day": 1, "level": 2, "layer": "text"}, {"week": 1, "day": 1, "level": 4, "layer": "sky"}, {"week": 2, "day": 1, "level": 3, "layer": "new"}]
+	}`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := d.layers(nil)
+	var names []string
+	for _, l := range layers {
+		names = append(names, l.Name)
+	}
+	if len(names) != 4 || names[0] != "sky" || names[1] != "empty" || names[2] != "text" || names[3] != "new" {
+		t.Fatalf("layers = %v", names)
+	}
+	if layers[0].Blend != draw.BlendMax || layers[2].Blend != draw.BlendReplace {
+		t.Errorf("blends = %s, %s", layers[0].Blend, layers[2].Blend)
+	}
+	if g := draw.Flatten(layers...); g.At(1, 1) != 2 || g.At(2, 1) != 3 {
+		t.Errorf("flattened to %d and %d", g.At(1, 1), g.At(2, 1))
+	}
+
+	// a plain list takes the stack it is given, then the order of its
+	// points
+	if err := json.Unmarshal([]byte(`[{"week": 1, "day": 1, "level": 2, "layer": "text"}, {"week": 1, "day": 1, "level": 4, "layer": "sky"}]`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if layers := d.layers(nil); layers[0].Name != "text" {
+		t.Errorf("unlisted layers stack as %s, %s", layers[0].Name, layers[1].Name)
+	}
+	stack := []draw.Layer{{Name: "sky"}, {Name: "text", Blend: draw.BlendReplace}}
+	if g := draw.Flatten(d.layers(stack)...); g.At(1, 1) != 2 {
+		t.Errorf("text over sky = %d, want 2", g.At(1, 1))
+	}
+
+	// points without a level, from older files, are painted at level 1
+	if err := json.Unmarshal([]byte(`[{"week": 3, "day": 2}, {"week": 4, "day": 2, "level": 9}]`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if g := draw.Flatten(d.layers(nil)...); g.At(3, 2) != 1 || g.At(4, 2) != draw.MaxLevel {
+		t.Errorf("unlevelled points = %d and %d", g.At(3, 2), g.At(4, 2))
+	}
+
+	for _, bad := range []string{
+		`{"layers": [{"name": "a", "blend": "multiply"}], "points": []}`,
+		`{"layers": [{"name": "a", "levels": [0, 1]}], "points": []}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), &d); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestLayerFlag(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logo.json")
+	os.WriteFile(file, []byte(`[{"week": 10, "day": 3, "level": 4}]`), 0644)
+
+	opts, err := parseRunFlags([]string{"--layer", "noise:max:0,1,1,1,1", "--layer", file + ":replace", "--seed", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.drawsText() {
+		t.Error("layers without text ask for text")
+	}
+	if l := opts.layers[0]; l.Name != "noise" || l.Blend != draw.BlendMax || len(l.Levels) != 5 {
+		t.Errorf("pattern layer = %+v", l.Layer)
+	}
+	if l := opts.layers[1]; l.Name != "logo" || l.Blend != draw.BlendReplace || l.Grid.At(10, 3) != 4 {
+		t.Errorf("file layer = %s %s", l.Name, l.Blend)
+	}
+	grid, _, err := opts.design("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid.At(10, 3) != 4 {
+		t.Errorf("the file layer is not on top: %d", grid.At(10, 3))
+	}
+	for _, c := range grid.Points() {
+		if c.Level > 1 && (c.Week != 10 || c.Day != 3) {
+			t.Fatalf("noise level %d at %d,%d got past its levels", c.Level, c.Week, c.Day)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--layer", "nope.json"},
+		{"--layer", "noise:max:0,1"},
+		{"--layer", "text", "--pattern", "noise"},
+	} {
+		if _, err := parseRunFlags(args); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+}
+
+func TestShardFlag(t *testing.T) {
+	// github shows a single year, so there is nothing to split by year
+	if _, err := parseRunFlags([]string{"--shard", "year"}); err == nil {
+		t.Error("--shard year accepted on github")
+	}
+	if _, err := parseRunFlags([]string{"--shard", "year", "--calendar", "gitlab"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/levels"
+	"github.com/1etu/gitdraw/schedule"
+)
+
+const scheduleUsage = "usage: gitdraw schedule add <name> [design.json] [options] | list | tick [name...] | run [--every 1h] | remove <name>"
+
+// runSchedule is `gitdraw schedule`: designs drawn forward in real time,
+// see package schedule.
+func runSchedule(args []string) {
+	setupOutput(outputText)
+	if len(args) == 0 {
+		exit(scheduleUsage)
+	}
+	dir, err := schedule.DefaultDir()
+	if err != nil {
+		exit(err.Error())
+	}
+
+	switch args[0] {
+	case "add":
+		addSchedule(dir, args[1:])
+	case "list":
+		listSchedules(dir)
+	case "tick":
+		fmt.Fprintln(console)
+		if !tickSchedules(dir, args[1:], time.Now()) {
+			fmt.Fprintln(console)
+			os.Exit(1)
+		}
+		fmt.Fprintln(console)
+	case "run":
+		runScheduler(dir, args[1:])
+	case "remove":
+		if len(args) != 2 {
+			exit("usage: gitdraw schedule remove <name>")
+		}
+		if err := schedule.Remove(dir, args[1]); err != nil {
+			exit(err.Error())
+		}
+		fmt.Fprintln(console)
+		success("removed " + args[1] + ", its commits are kept")
+		fmt.Fprintln(console)
+	default:
+		exit(scheduleUsage)
+	}
+}
+
+// addSchedule plans a design from today to the end of its year and saves
+// it as a schedule. It takes the options of the interactive CLI.
+func addSchedule(dir string, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		exit("usage: gitdraw schedule add <name> [design.json] [options]")
+	}
+	name, args := args[0], args[1:]
+	if !schedule.ValidName(name) {
+		exit(fmt.Sprintf("bad schedule name %q", name))
+	}
+	if _, err := schedule.Load(dir, name); err == nil {
+		exit("there is already a schedule called " + name)
+	}
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	var missed string
+	opts, err := parseRunFlags(args, func(fs *flag.FlagSet) {
+		fs.StringVar(&missed, "missed", string(schedule.Skip), "what to do about missed days: "+strings.Join(schedule.Policies, " or "))
+	})
+	var policy schedule.Policy
+	if err == nil {
+		policy, err = schedule.ParsePolicy(missed)
+	}
+	if err != nil {
+		exit(err.Error())
+	}
+
+	var grid draw.Grid
+	if file != "" {
+		grid, err = readPoints(file)
+	} else if opts.cfg.Text != "" || opts.pattern != "" || opts.layers != nil {
+		grid, _, err = opts.design(opts.cfg.Text)
+	} else {
+		err = fmt.Errorf("give a design file, --text, --pattern or --layer")
+	}
+	if err != nil {
+		exit(err.Error())
+	}
+
+	now := time.Now()
+	year := opts.year()
+	if draw.Rolling(opts.calendar) && year != now.Year() {
+		warn(fmt.Sprintf("%s only shows the last year, a design for %d will not be visible", opts.calendar.Name(), year))
+	}
+	intensity := opts.cfg.Intensity
+	if intensity == 0 {
+		intensity = 15
+	}
+	fill := opts.cfg.Fill != nil && *opts.cfg.Fill
+
+	// plan the whole year, so it shades as one, then keep the days to come
+	_, until := opts.calendar.Window(year)
+	plan := levels.PlanGrid(grid, year, levels.Options{Calendar: opts.calendar, Fill: fill, Top: intensity, Existing: opts.existingCounts(), Until: until})
+	if loc := opts.cfg.Location(); loc != nil {
+		now = now.In(loc)
+	}
+	today := now.Format("2006-01-02")
+	var days []schedule.Day
+	past := 0
+	for _, d := range schedule.FromPlan(plan) {
+		if d.Date < today {
+			past++
+			continue
+		}
+		days = append(days, d)
+	}
+	if len(days) == 0 {
+		exit(fmt.Sprintf("nothing of the design is left to draw in %d", year))
+	}
+
+	repoPath := opts.cfg.Output
+	if repoPath == "" {
+		repoPath = name
+	}
+	repoPath, err = filepath.Abs(repoPath)
+	if err != nil {
+		exit(err.Error())
+	}
+	repo, err := git.OpenRoot(repoPath)
+	local := ""
+	if err != nil {
+		if repo, err = git.Init(repoPath); err != nil {
+			exit(err.Error())
+		}
+		local = git.Branch
+	} else if local, err = repo.CurrentBranch(); err != nil {
+		exit(err.Error())
+	}
+	if remote := normalizeRemote(opts.cfg.Remote); remote != "" {
+		if err := repo.AddRemote(opts.push.Remote, remote); err != nil {
+			exit("failed to add remote: " + err.Error())
+		}
+	}
+
+	s := &schedule.Schedule{
+		Name:     name,
+		Repo:     repo.Path,
+		Author:   opts.cfg.Author,
+		Email:    opts.cfg.Email,
+		Timezone: opts.cfg.Timezone,
+		Style:    opts.style,
+		Policy:   policy,
+		Days:     days,
+	}
+	if repo.HasRemote(opts.push.Remote) {
+		s.Push = opts.push
+	}
+	s.Push.Local = local
+	if err := s.Save(dir); err != nil {
+		exit(err.Error())
+	}
+
+	fmt.Fprintln(console)
+	printPreview(opts.calendar, grid)
+	info("schedule", name)
+	info("repository", s.Repo)
+	info("days", fmt.Sprintf("%d, %s to %s", len(days), days[0].Date, days[len(days)-1].Date))
+	info("commits", fmt.Sprintf("%d", s.Total()))
+	info("missed days", string(s.Policy))
+	if s.Push.Remote != "" {
+		info("pushes to", s.Push.Remote)
+	}
+	if past > 0 {
+		warn(fmt.Sprintf("%d days before today are left out", past))
+	}
+	if n := len(plan.Unsatisfiable); n > 0 {
+		warn(fmt.Sprintf("%d cells cannot show at their level on %s", n, opts.calendar.Name()))
+	}
+	fmt.Fprintln(console)
+	fmt.Fprintln(console, dim+"  Run `gitdraw schedule tick` daily (cron, a timer) or keep"+reset)
+	fmt.Fprintln(console, dim+"  `gitdraw schedule run` going to make each day's commits."+reset)
+	fmt.Fprintln(console)
+}
+
+func listSchedules(dir string) {
+	list, err := schedule.List(dir)
+	if err != nil {
+		exit(err.Error())
+	}
+	fmt.Fprintln(console)
+	if len(list) == 0 {
+		fmt.Fprintln(console, dim+"  No schedules. Add one with `gitdraw schedule add <name>`."+reset)
+		fmt.Fprintln(console)
+		return
+	}
+	now := time.Now()
+	for _, s := range list {
+		state := "done " + fmt.Sprint(doneCommits(s)) + "/" + fmt.Sprint(s.Total())
+		if s.Finished(now) {
+			state += ", finished"
+		}
+		fmt.Fprintf(console, "  %s%s%s  %s%s%s\n", bold, s.Name, reset, dim, s.Repo, reset)
+		fmt.Fprintf(console, "    ")
+		if len(s.Days) > 0 {
+			fmt.Fprintf(console, "%s to %s, ", s.Days[0].Date, s.Days[len(s.Days)-1].Date)
+		}
+		fmt.Fprintf(console, "%s, missed days: %s", state, s.Policy)
+		if len(s.Missed) > 0 {
+			fmt.Fprintf(console, " (%d skipped)", len(s.Missed))
+		}
+		fmt.Fprintln(console)
+		if !s.LastTick.IsZero() {
+			fmt.Fprintf(console, "    %slast tick %s%s\n", dim, s.LastTick.Format("Jan 2 15:04"), reset)
+		}
+	}
+	fmt.Fprintln(console)
+}
+
+// doneCommits counts the schedule's commits in its repository, -1 if it
+// cannot be read.
+func doneCommits(s *schedule.Schedule) int {
+	repo, err := git.OpenRoot(s.Repo)
+	if err != nil {
+		return -1
+	}
+	branch := s.Push.Local
+	if branch == "" {
+		branch, _ = repo.CurrentBranch()
+	}
+	counts, err := repo.TrailerCounts(branch, schedule.Trailer, s.Name)
+	if err != nil {
+		return -1
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+// tickSchedules ticks the named schedules, or all of them, and saves
+// them. It reports whether every tick went well.
+func tickSchedules(dir string, names []string, now time.Time) bool {
+	list, err := schedule.List(dir)
+	if err != nil {
+		warn(err.Error())
+		return false
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(list, func(s *schedule.Schedule) bool { return s.Name == name }) {
+			warn("no schedule called " + name)
+			return false
+		}
+	}
+
+	ok := true
+	for _, s := range list {
+		// a finished schedule still has days to backfill
+		if len(names) > 0 && !slices.Contains(names, s.Name) || s.Finished(now) && s.Policy != schedule.Backfill {
+			continue
+		}
+		res, err := s.Tick(now)
+		if saveErr := s.Save(dir); err == nil {
+			err = saveErr
+		}
+		if err != nil {
+			warn(s.Name + ": " + err.Error())
+			ok = false
+			continue
+		}
+		if len(res.Missed) > 0 {
+			verb := "skipped"
+			if s.Policy == schedule.Backfill {
+				verb = "backfilled"
+			}
+			warn(fmt.Sprintf("%s: missed %s, %s", s.Name, strings.Join(res.Missed, ", "), verb))
+		}
+		msg := fmt.Sprintf("%s: %d commits", s.Name, res.Commits)
+		if res.Backfilled > 0 {
+			msg += fmt.Sprintf(", %d backfilled", res.Backfilled)
+		}
+		if res.Pushed {
+			msg += ", pushed"
+		}
+		success(msg)
+	}
+	return ok
+}
+
+// runScheduler is the daemon: it ticks every schedule now and then every
+// interval, so each day's commits are made early in the day.
+func runScheduler(dir string, args []string) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	every := fs.Duration("every", time.Hour, "how often to tick")
+	if err := fs.Parse(args); err != nil {
+		exit(err.Error())
+	}
+	if *every < time.Minute {
+		exit("--every must be at least a minute")
+	}
+
+	fmt.Fprintln(console)
+	success(fmt.Sprintf("ticking every %s, stop with ctrl-c", *every))
+	for {
+		now := time.Now()
+		fmt.Fprintf(console, "\n  %s%s%s\n", dim, now.Format("Jan 2 15:04"), reset)
+		tickSchedules(dir, nil, now)
+		time.Sleep(time.Until(now.Add(*every)))
+	}
+}
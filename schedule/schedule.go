@@ -0,0 +1,322 @@
+// Package schedule draws a design forward in real time. Instead of
+// backdating a year of commits at once, a schedule keeps the design's
+// commits by day and makes each day's commits on that day, with the time
+// they are made, so the history looks as if it had been written then.
+//
+// Schedules are kept as JSON files, one per schedule, so a daemon or a
+// one-shot tick from cron picks up where the last run stopped. The commits
+// themselves are the record of what is done: each carries Trailer with
+// the schedule's name, and a day is done once it has all of them.
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/levels"
+)
+
+// Trailer marks the commits of a schedule, with its name as the value.
+const Trailer = "Gitdraw-Schedule"
+
+// Policy is what a tick does about days that passed without their
+// commits, because nothing ran that day.
+type Policy string
+
+const (
+	// Skip leaves missed days out; the design shows a gap.
+	Skip Policy = "skip"
+	// Backfill makes a missed day's commits late, dated on that day. They
+	// are the only backdated commits of a schedule.
+	Backfill Policy = "backfill"
+)
+
+// Policies lists the accepted policies.
+var Policies = []string{string(Skip), string(Backfill)}
+
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "", Skip:
+		return Skip, nil
+	case Backfill:
+		return Backfill, nil
+	}
+	return Skip, fmt.Errorf("unknown missed day policy: %s", s)
+}
+
+// Day is one day of the design.
+type Day struct {
+	Date    string `json:"date"`
+	Week    int    `json:"week"`
+	Day     int    `json:"day"`
+	Level   int    `json:"level"`
+	Commits int    `json:"commits"`
+}
+
+// Schedule is a design spread over its days and where it is drawn.
+type Schedule struct {
+	Name string `json:"name"`
+	// Repo is the path of the repository the commits are made in.
+	Repo   string          `json:"repo"`
+	Push   git.PushOptions `json:"push"`
+	Author string          `json:"author,omitempty"`
+	Email  string          `json:"email,omitempty"`
+	// Timezone decides which day it is, and dates the commits. Empty for
+	// the local time.
+	Timezone string    `json:"timezone,omitempty"`
+	Style    git.Style `json:"style"`
+	Policy   Policy    `json:"policy"`
+	Days     []Day     `json:"days"`
+
+	// Missed are the days a tick found past and not done, and skipped.
+	Missed []string `json:"missed,omitempty"`
+	// LastTick is when the schedule last ran.
+	LastTick time.Time `json:"last_tick,omitempty"`
+}
+
+// FromPlan makes the days of a schedule from a plan, dropping the days
+// that take no commits.
+func FromPlan(p levels.Plan) []Day {
+	var days []Day
+	for _, c := range append(append([]draw.Cell{}, p.Background...), p.Foreground...) {
+		if c.Commits > 0 {
+			days = append(days, Day{Date: c.Date.Format("2006-01-02"), Week: c.Week, Day: c.Day, Level: c.Level, Commits: c.Commits})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// Location is the timezone of the schedule.
+func (s *Schedule) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Total is the number of commits the design takes.
+func (s *Schedule) Total() int {
+	total := 0
+	for _, d := range s.Days {
+		total += d.Commits
+	}
+	return total
+}
+
+// Due is what a tick at now has to commit, given the schedule's commits
+// already made on each day: the rest of today's, and, when backfilling,
+// the rest of every missed day's. Missed lists the past days that are
+// not done, whatever the policy.
+func (s *Schedule) Due(now time.Time, done levels.Counts) (due []draw.Cell, missed []string) {
+	today := now.In(s.Location()).Format("2006-01-02")
+	for _, d := range s.Days {
+		if d.Date > today {
+			break
+		}
+		date, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			continue
+		}
+		left := d.Commits - done.Get(date)
+		if left <= 0 {
+			continue
+		}
+		if d.Date < today {
+			missed = append(missed, d.Date)
+			if s.Policy != Backfill {
+				continue
+			}
+		}
+		due = append(due, draw.Cell{Week: d.Week, Day: d.Day, Level: d.Level, Date: date.Add(12 * time.Hour), Commits: left})
+	}
+	return due, missed
+}
+
+// Finished reports whether every day of the schedule is past at now.
+func (s *Schedule) Finished(now time.Time) bool {
+	return len(s.Days) == 0 || s.Days[len(s.Days)-1].Date < now.In(s.Location()).Format("2006-01-02")
+}
+
+// Result is what a tick did.
+type Result struct {
+	// Commits were made today, with the time of the tick.
+	Commits int
+	// Backfilled commits were made for missed days, dated on them.
+	Backfilled int
+	// Missed are the past days found not done for the first time.
+	Missed []string
+	Pushed bool
+}
+
+// Tick makes the commits due at now in the schedule's repository and
+// pushes them if it has a remote. Ticking again the same day adds nothing,
+// so it is safe to run as often as wanted.
+func (s *Schedule) Tick(now time.Time) (Result, error) {
+	var res Result
+	repo, err := git.OpenRoot(s.Repo)
+	if err != nil {
+		return res, err
+	}
+	repo.Author, repo.Email = s.Author, s.Email
+	repo.Location = s.Location()
+	repo.Style = s.Style
+	repo.Trailers = []string{Trailer + ": " + s.Name}
+
+	branch := s.Push.Local
+	if branch == "" {
+		if branch, err = repo.CurrentBranch(); err != nil {
+			return res, err
+		}
+	}
+	done, err := repo.TrailerCounts(branch, Trailer, s.Name)
+	if err != nil {
+		return res, err
+	}
+
+	due, missed := s.Due(now, done)
+	for _, d := range missed {
+		if !slices.Contains(s.Missed, d) {
+			res.Missed = append(res.Missed, d)
+		}
+	}
+	now = now.In(s.Location())
+	today := now.Format("2006-01-02")
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// today's commits are made now, a second apart, and missed days' are
+	// spread over their day as usual
+	var cells []draw.Cell
+	for _, c := range due {
+		if c.Date.Format("2006-01-02") != today {
+			res.Backfilled += c.Commits
+			cells = append(cells, c)
+			continue
+		}
+		first := now.Add(-time.Duration(c.Commits-1) * time.Second)
+		if first.Before(midnight) {
+			first = midnight
+		}
+		for i := 0; i < c.Commits; i++ {
+			one := c
+			one.Date, one.Commits = first.Add(time.Duration(i)*time.Second), 1
+			cells = append(cells, one)
+		}
+		res.Commits += c.Commits
+	}
+
+	if err := repo.Append(branch, cells, nil); err != nil {
+		return res, err
+	}
+	s.LastTick = now
+	if s.Policy != Backfill {
+		s.Missed = append(s.Missed, res.Missed...)
+	}
+
+	// pushing when nothing was added still sends what an earlier tick
+	// could not
+	if s.Push.Remote != "" {
+		push := s.Push
+		push.Local = branch
+		if push.Branch == "" {
+			push.Branch = branch
+		}
+		if err := repo.Push(push); err != nil {
+			return res, err
+		}
+		res.Pushed = true
+	}
+	return res, nil
+}
+
+// DefaultDir is where schedules are kept, in the user's config dir.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gitdraw", "schedules"), nil
+}
+
+// ValidName reports whether name can name a schedule and its file.
+func ValidName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\:`) && !strings.HasPrefix(name, ".")
+}
+
+func path(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// Load reads the schedule called name from dir.
+func Load(dir, name string) (*Schedule, error) {
+	data, err := os.ReadFile(path(dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no schedule called %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("schedule %s: %v", name, err)
+	}
+	return &s, nil
+}
+
+// Save writes the schedule to dir, replacing the file in one step.
+func (s *Schedule) Save(dir string) error {
+	if !ValidName(s.Name) {
+		return fmt.Errorf("bad schedule name %q", s.Name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path(dir, s.Name) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path(dir, s.Name))
+}
+
+// Remove deletes the schedule called name from dir. Its commits stay.
+func Remove(dir, name string) error {
+	err := os.Remove(path(dir, name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no schedule called %s", name)
+	}
+	return err
+}
+
+// List reads every schedule in dir, by name. A missing dir has none.
+func List(dir string) ([]*Schedule, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var list []*Schedule
+	for _, m := range matches {
+		s, err := Load(dir, strings.TrimSuffix(filepath.Base(m), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
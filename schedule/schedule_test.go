@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/levels"
+)
+
+func days() []Day {
+	return []Day{
+		{Date: "2027-03-01", Level: 4, Commits: 3},
+		{Date: "2027-03-02", Level: 1, Commits: 1},
+		{Date: "2027-03-04", Level: 4, Commits: 3},
+	}
+}
+
+func at(date string, hour int) time.Time {
+	d, _ := time.Parse("2006-01-02", date)
+	return d.Add(time.Duration(hour) * time.Hour)
+}
+
+func TestDue(t *testing.T) {
+	s := &Schedule{Timezone: "UTC", Days: days()}
+
+	if due, missed := s.Due(at("2027-02-28", 10), nil); len(due) != 0 || len(missed) != 0 {
+		t.Errorf("due before the first day: %v %v", due, missed)
+	}
+
+	due, _ := s.Due(at("2027-03-01", 10), nil)
+	if len(due) != 1 || due[0].Commits != 3 {
+		t.Errorf("first day due %+v", due)
+	}
+
+	// a tick on the 4th finds the 2nd missed and the 1st half done
+	done := levels.Counts{}
+	done.Add(at("2027-03-01", 0), 2)
+	due, missed := s.Due(at("2027-03-04", 10), done)
+	if len(due) != 1 || due[0].Commits != 3 || strings.Join(missed, ",") != "2027-03-01,2027-03-02" {
+		t.Errorf("skip: due %+v, missed %v", due, missed)
+	}
+	s.Policy = Backfill
+	due, _ = s.Due(at("2027-03-04", 10), done)
+	if len(due) != 3 || due[0].Commits != 1 {
+		t.Errorf("backfill: due %+v", due)
+	}
+
+	if !s.Finished(at("2027-03-05", 0)) || s.Finished(at("2027-03-04", 23)) {
+		t.Error("finished on the wrong day")
+	}
+	if _, err := ParsePolicy("later"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s := &Schedule{Name: "hello", Repo: "/tmp/repo", Policy: Backfill, Days: days()}
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Schedule{Name: "../up"}).Save(dir); err == nil {
+		t.Error("saved a schedule outside the dir")
+	}
+
+	got, err := Load(dir, "hello")
+	if err != nil || got.Policy != Backfill || len(got.Days) != 3 || got.Total() != 7 {
+		t.Fatalf("loaded %+v, %v", got, err)
+	}
+	list, err := List(dir)
+	if err != nil || len(list) != 1 || list[0].Name != "hello" {
+		t.Errorf("list = %v, %v", list, err)
+	}
+	if err := Remove(dir, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir, "hello"); err == nil {
+		t.Error("removed schedule still loads")
+	}
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestTick(t *testing.T) {
+	dir := t.TempDir()
+	run(t, dir, "init", "-q", "-b", "main")
+
+	s := &Schedule{Name: "hello", Repo: dir, Timezone: "UTC", Days: days()}
+	res, err := s.Tick(at("2027-03-01", 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Commits != 3 {
+		t.Errorf("first tick made %d commits", res.Commits)
+	}
+	// made with the time of the tick, not backdated
+	if date := run(t, dir, "log", "-1", "--format=%ad", "--date=iso"); date != "2027-03-01 15:00:00 +0000" {
+		t.Errorf("last commit dated %s", date)
+	}
+
+	if res, _ := s.Tick(at("2027-03-01", 18)); res.Commits != 0 {
+		t.Errorf("second tick the same day made %d commits", res.Commits)
+	}
+
+	// nothing ran on the 2nd
+	res, err = s.Tick(at("2027-03-04", 9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Commits != 3 || res.Backfilled != 0 || strings.Join(res.Missed, ",") != "2027-03-02" {
+		t.Errorf("skipping tick: %+v", res)
+	}
+	if strings.Join(s.Missed, ",") != "2027-03-02" || !s.LastTick.Equal(at("2027-03-04", 9)) {
+		t.Errorf("state after the tick: %v, %v", s.Missed, s.LastTick)
+	}
+	if res, _ := s.Tick(at("2027-03-04", 10)); len(res.Missed) != 0 {
+		t.Errorf("missed days reported again: %v", res.Missed)
+	}
+
+	s.Policy = Backfill
+	res, err = s.Tick(at("2027-03-04", 11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Backfilled != 1 {
+		t.Errorf("backfilled %d commits", res.Backfilled)
+	}
+	if n := run(t, dir, "rev-list", "--count", "main"); n != "7" {
+		t.Errorf("%s commits in all, want 7", n)
+	}
+}
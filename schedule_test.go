@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/1etu/gitdraw/schedule"
+)
+
+func TestMissedFlag(t *testing.T) {
+	// --missed belongs to schedule add, not to a plain run
+	if _, err := parseRunFlags([]string{"--missed", "backfill"}); err == nil {
+		t.Error("a plain run accepted --missed")
+	}
+}
+
+func TestListEmptySchedule(t *testing.T) {
+	dir := t.TempDir()
+	s := &schedule.Schedule{Name: "empty", Repo: t.TempDir(), Policy: schedule.Skip}
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	old := console
+	console = &out
+	defer func() { console = old }()
+
+	listSchedules(dir)
+	if !strings.Contains(out.String(), "empty") {
+		t.Errorf("list = %q", out.String())
+	}
+}
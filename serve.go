@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/1etu/gitdraw/config"
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/jobs"
+)
+
+// appShim stands in for the Wails bindings when the GUI is opened in a
+// browser, sending every App call to /api/call.
+const appShim = `window.go = {main: {App: new Proxy({}, {
+	get: (_, name) => (...args) => fetch('/api/call/' + name, {
+		method: 'POST',
+		headers: {'Content-Type': 'application/json'},
+		body: JSON.stringify(args),
+	}).then(r => r.ok ? r.text() : Promise.reject(new Error(r.statusText))),
+})}};
+`
+
+// runServe is `gitdraw serve`: the App operations over HTTP/JSON, plus the
+// GUI itself.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	configPath := fs.String("config", "", "config file")
+	limit := fs.Int("jobs", jobs.DefaultLimit, "jobs to run at once")
+	if err := fs.Parse(args); err != nil {
+		exit(err.Error())
+	}
+	setupOutput(outputText)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		exit(err.Error())
+	}
+
+	app := NewApp(cfg)
+	// unlike the gui, a server only force pushes when told to
+	app.settings.push.Force = git.ForceNone
+	if *limit != jobs.DefaultLimit {
+		app.openJobs(*limit)
+	}
+	if app.jobs == nil {
+		warn("jobs disabled: " + app.jobsErr.Error())
+	}
+
+	s := &server{app: app, addr: *addr}
+	fmt.Fprintln(console)
+	success("serving on http://" + *addr)
+	fmt.Fprintln(console)
+	if err := http.ListenAndServe(*addr, s.routes()); err != nil {
+		exit(err.Error())
+	}
+}
+
+type server struct {
+	app *App
+	// addr is the address the server listens on.
+	addr string
+}
+
+// callable are the App methods /api/call runs: the ones the GUI uses.
+// The rest, such as SetPushOptions, are left to the GUI build.
+var callable = map[string]bool{
+	"CalendarLayout":  true,
+	"CancelJob":       true,
+	"Defaults":        true,
+	"ExistingPoints":  true,
+	"Generate":        true,
+	"ImageToPoints":   true,
+	"ImportCalendar":  true,
+	"Jobs":            true,
+	"LoadActivity":    true,
+	"OverlayPoints":   true,
+	"PatternToPoints": true,
+	"Plan":            true,
+	"PreviewSVG":      true,
+	"QueueGenerate":   true,
+	"SetCalendar":     true,
+	"SetLayer":        true,
+	"SpritePoints":    true,
+	"Sprites":         true,
+	"TextToPoints":    true,
+	"TransformPoints": true,
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/text", s.handleText)
+	mux.HandleFunc("POST /api/image", s.handleImage)
+	mux.HandleFunc("POST /api/plan", s.handlePlan)
+	mux.HandleFunc("POST /api/preview", s.handlePreview)
+	mux.HandleFunc("POST /api/generate", s.handleGenerate)
+	mux.HandleFunc("GET /api/jobs", s.handleJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /api/jobs/{id}/events", s.handleJobEvents)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("POST /api/call/{method}", s.handleCall)
+
+	mux.HandleFunc("GET /wailsjs/go/main/App.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		io.WriteString(w, appShim)
+	})
+	mux.HandleFunc("GET /wailsjs/runtime/runtime.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+	})
+	gui, _ := fs.Sub(assets, "gui")
+	mux.Handle("GET /", http.FileServer(http.FS(gui)))
+	return s.local(mux)
+}
+
+// local only lets through requests for this server by name. The JSON
+// content type keeps other pages from posting across origins, but a page
+// whose DNS name is rebound to this machine is same-origin to the
+// browser; its Host and Origin still carry that name.
+func (s *server) local(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowed(r.Host) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !s.allowed(u.Host) {
+				http.Error(w, "forbidden origin", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowed reports whether host, with or without a port, names this
+// server: its listen address or a loopback name.
+func (s *server) allowed(host string) bool {
+	if host == "" {
+		return false
+	}
+	if host == s.addr {
+		return true
+	}
+	name, _, err := net.SplitHostPort(host)
+	if err != nil {
+		name = host
+	}
+	name = strings.Trim(name, "[]")
+	if name == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && ip.IsLoopback()
+}
+
+// decode reads a JSON request body. Requiring a JSON content type means
+// browsers preflight cross-site requests, which this server never
+// approves, so other web pages cannot post to it.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if !isJSON(w, r) {
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func isJSON(w http.ResponseWriter, r *http.Request) bool {
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		http.Error(w, "expected application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeRawJSON(w http.ResponseWriter, data string) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, data)
+}
+
+func (s *server) handleText(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+		Year int    `json:"year"`
+	}
+	if decode(w, r, &req) {
+		writeRawJSON(w, s.app.TextToPoints(req.Text, req.Year))
+	}
+}
+
+// handleImage takes the image file as the request body, with an image/*
+// content type.
+func (s *server) handleImage(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "image/") {
+		http.Error(w, "expected an image", http.StatusUnsupportedMediaType)
+		return
+	}
+	img, _, err := image.Decode(r.Body)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	invert, _ := strconv.ParseBool(r.URL.Query().Get("invert"))
+	writeRawJSON(w, gridToPoints(draw.FromImage(img, invert)))
+}
+
+// decodeRequest reads a GenerateRequest, rejecting unknown calendars.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (GenerateRequest, bool) {
+	var req GenerateRequest
+	if !decode(w, r, &req) {
+		return req, false
+	}
+	if _, err := draw.ParseCalendar(req.Calendar); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func (s *server) handlePlan(w http.ResponseWriter, r *http.Request) {
+	if req, ok := decodeRequest(w, r); ok {
+		writeJSON(w, http.StatusOK, s.app.plan(req))
+	}
+}
+
+func (s *server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	if req, ok := decodeRequest(w, r); ok {
+		st := s.app.current()
+		grid := draw.Flatten(req.Points.layers(st.layers)...)
+		if req.Overlay {
+			grid = st.overlay(req)
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		io.WriteString(w, grid.SVG(st.calendar(req)))
+	}
+}
+
+func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	j, err := s.app.submit(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if s.app.jobs == nil {
+		writeJSON(w, http.StatusOK, []jobs.Job{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.app.jobs.List())
+}
+
+// watch looks up the job in the request path.
+func (s *server) watch(w http.ResponseWriter, r *http.Request) (jobs.Job, <-chan struct{}, bool) {
+	if s.app.jobs != nil {
+		if j, changed, ok := s.app.jobs.Watch(r.PathValue("id")); ok {
+			return j, changed, true
+		}
+	}
+	http.NotFound(w, r)
+	return jobs.Job{}, nil, false
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if j, _, ok := s.watch(w, r); ok {
+		writeJSON(w, http.StatusOK, j)
+	}
+}
+
+// handleCancel takes no body, but still wants the JSON content type that
+// keeps other pages out.
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if !isJSON(w, r) {
+		return
+	}
+	if _, _, ok := s.watch(w, r); !ok {
+		return
+	}
+	if err := s.app.jobs.Cancel(r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJobEvents streams a job's state as server-sent events until it
+// finishes.
+func (s *server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := s.watch(w, r); !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for {
+		j, changed, _ := s.app.jobs.Watch(r.PathValue("id"))
+		data, _ := json.Marshal(j)
+		event := "progress"
+		if j.Finished() {
+			event = "result"
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+		if event == "result" {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleCall runs one of the callable App methods by name, with the
+// arguments as a JSON array, the way the Wails bindings do.
+func (s *server) handleCall(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("method")
+	if !callable[name] {
+		http.NotFound(w, r)
+		return
+	}
+	method := reflect.ValueOf(s.app).MethodByName(name)
+	if !method.IsValid() || method.Type().NumOut() != 1 || method.Type().Out(0).Kind() != reflect.String {
+		http.NotFound(w, r)
+		return
+	}
+	var raw []json.RawMessage
+	if !decode(w, r, &raw) {
+		return
+	}
+	t := method.Type()
+	if len(raw) != t.NumIn() {
+		http.Error(w, fmt.Sprintf("want %d arguments", t.NumIn()), http.StatusBadRequest)
+		return
+	}
+	args := make([]reflect.Value, len(raw))
+	for i, arg := range raw {
+		v := reflect.New(t.In(i))
+		if err := json.Unmarshal(arg, v.Interface()); err != nil {
+			http.Error(w, fmt.Sprintf("argument %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+		args[i] = v.Elem()
+	}
+	io.WriteString(w, method.Call(args)[0].String())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+// testServer is a server without a job manager, so tests leave the user's
+// config dir alone.
+func testServer() *server {
+	app := &App{settings: settings{cal: draw.GitHub{}}}
+	return &server{app: app, addr: "127.0.0.1:8080"}
+}
+
+func request(h http.Handler, method, path, contentType, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Host = "127.0.0.1:8080"
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		if header[i] == "Host" {
+			r.Host = header[i+1]
+		} else {
+			r.Header.Set(header[i], header[i+1])
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRoutes(t *testing.T) {
+	h := testServer().routes()
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+		prefix             string
+	}{
+		{"POST", "/api/text", `{"text":"hi"}`, http.StatusOK, `[{`},
+		{"POST", "/api/plan", `{"points":[{"week":1,"day":1,"level":4}],"year":2025,"intensity":3}`, http.StatusOK, `{"event":"plan"`},
+		{"POST", "/api/preview", `{"points":[]}`, http.StatusOK, `<svg`},
+		{"POST", "/api/preview", `{"points":{"layers":[{"name":"a","blend":"add"}],"points":[{"week":1,"day":1,"level":2,"layer":"a"}]}}`, http.StatusOK, `<svg`},
+		{"POST", "/api/preview", `{"points":{"layers":[{"name":"a","blend":"nope"}],"points":[]}}`, http.StatusBadRequest, ""},
+		{"POST", "/api/preview", `{"points":[],"calendar":"nope"}`, http.StatusBadRequest, ""},
+		{"GET", "/api/jobs", "", http.StatusOK, `[]`},
+		{"GET", "/api/jobs/1", "", http.StatusNotFound, ""},
+		{"POST", "/api/call/TextToPoints", `["hi",2025]`, http.StatusOK, `[{`},
+		{"POST", "/api/call/TextToPoints", `[]`, http.StatusBadRequest, ""},
+		{"POST", "/api/call/SetLayer", `["text","max",""]`, http.StatusOK, `success`},
+		// settings the GUI does not use are not callable over HTTP
+		{"POST", "/api/call/SetPushOptions", `["origin","main","force"]`, http.StatusNotFound, ""},
+		{"POST", "/api/call/openJobs", `[1]`, http.StatusNotFound, ""},
+		{"GET", "/wailsjs/go/main/App.js", "", http.StatusOK, `window.go`},
+	} {
+		w := request(h, tt.method, tt.path, "application/json", tt.body)
+		if w.Code != tt.status || !strings.HasPrefix(w.Body.String(), tt.prefix) {
+			t.Errorf("%s %s = %d %.40q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.status, tt.prefix)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	h := testServer().routes()
+	for _, path := range []string{"/api/text", "/api/plan", "/api/generate", "/api/call/TextToPoints", "/api/jobs/1/cancel"} {
+		if w := request(h, "POST", path, "text/plain", `{}`); w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("text/plain post to %s = %d", path, w.Code)
+		}
+	}
+	if w := request(h, "POST", "/api/image", "application/json", `{}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("json post to /api/image = %d", w.Code)
+	}
+}
+
+func TestHost(t *testing.T) {
+	h := testServer().routes()
+	for _, tt := range []struct {
+		header []string
+		status int
+	}{
+		{nil, http.StatusOK},
+		{[]string{"Host", "localhost:8080"}, http.StatusOK},
+		{[]string{"Host", "[::1]:8080"}, http.StatusOK},
+		{[]string{"Origin", "http://127.0.0.1:8080"}, http.StatusOK},
+		// a rebound name reaches the server, but not the API
+		{[]string{"Host", "evil.example.com"}, http.StatusForbidden},
+		{[]string{"Host", "evil.example.com:8080"}, http.StatusForbidden},
+		{[]string{"Origin", "http://evil.example.com:8080"}, http.StatusForbidden},
+		{[]string{"Origin", "null"}, http.StatusForbidden},
+	} {
+		w := request(h, "POST", "/api/text", "application/json", `{"text":"hi"}`, tt.header...)
+		if w.Code != tt.status {
+			t.Errorf("%v: %d, want %d", tt.header, w.Code, tt.status)
+		}
+	}
+}
+
+// TestConcurrentCalls is for -race: handlers run at the same time.
+func TestConcurrentCalls(t *testing.T) {
+	s := testServer()
+	h := s.routes()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.app.SetLayer("text", "max", "0,1,2,3,4")
+			s.app.SetCalendar("gitlab")
+		}()
+		go func() {
+			defer wg.Done()
+			request(h, "POST", "/api/preview", "application/json", `{"points":[{"week":1,"day":1,"level":2,"layer":"text"}]}`)
+			s.app.Plan(`[]`, 2025, 3, false)
+		}()
+	}
+	wg.Wait()
+}
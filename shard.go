@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/git"
+	"github.com/1etu/gitdraw/levels"
+	"github.com/1etu/gitdraw/shard"
+)
+
+// generateShards splits plan with --shard and generates each part in its
+// own repository next to base, pushing each to its own remote. layers
+// are the design's, for --shard layer. One part failing does not stop the
+// others.
+func generateShards(opts runOptions, plan levels.Plan, layers []draw.Layer, base string, design *git.Design) {
+	shards, err := shard.Split(plan, opts.shardBy, opts.shards, layers)
+	if err != nil {
+		exit(err.Error())
+	}
+	if len(shards) == 0 {
+		exit("the design has no commits to split")
+	}
+
+	fmt.Fprintln(console)
+	total := 0
+	for _, s := range shards {
+		info(s.Name, fmt.Sprintf("%d commits in %s", s.Commits(), shard.Dir(base, s.Name)))
+		total += s.Commits()
+	}
+	if len(shards) == 1 {
+		warn(fmt.Sprintf("splitting by %s leaves a single repository", opts.shardBy))
+	}
+
+	fmt.Fprintln(console)
+	if !confirm(fmt.Sprintf("Generate %d commits in %d repositories", total, len(shards))) {
+		fmt.Fprintln(console, dim+"Cancelled."+reset)
+		result.Error = "cancelled"
+		return
+	}
+
+	var remote string
+	if confirm("Configure GitHub remotes") {
+		fmt.Fprintln(console)
+		fmt.Fprintln(console, dim+"  Create an empty repo at github.com/new (no README) for each part."+reset)
+		fmt.Fprintln(console, dim+"  {shard} in the URL is replaced by the part's name, otherwise the"+reset)
+		fmt.Fprintln(console, dim+"  name is added to the end, as in art-2025.git."+reset)
+		fmt.Fprintln(console)
+		if opts.cfg.Remote != "" {
+			remote = askWithDefault("GitHub URL", opts.cfg.Remote)
+		} else {
+			remote = ask("GitHub URL (or enter to skip)")
+		}
+		remote = normalizeRemote(remote)
+	}
+
+	result.Repo, result.Remote = base, remote
+	failed := 0
+	for _, s := range shards {
+		ev := generateShard(opts, s, base, remote, design)
+		result.Shards = append(result.Shards, ev)
+		result.Commits += ev.Commits
+		if ev.Error != "" {
+			failed++
+		}
+	}
+	result.Pushed = remote != "" && failed == 0
+
+	fmt.Fprintln(console)
+	if failed > 0 {
+		result.Error = fmt.Sprintf("%d of %d repositories failed", failed, len(shards))
+		warn(result.Error)
+		fmt.Fprintln(console, dim+"  Run again with the same design and directory to pick up the rest."+reset)
+		fmt.Fprintln(console)
+		return
+	}
+	success(fmt.Sprintf("%d repositories ready", len(shards)))
+	fmt.Fprintln(console)
+	if remote == "" {
+		fmt.Fprintln(console, dim+"  Push each one to its own repository on the same account:"+reset)
+		fmt.Fprintln(console)
+		fmt.Fprintf(console, "    cd %s\n", shard.Dir(base, shards[0].Name))
+		fmt.Fprintln(console, "    git remote add origin <url>")
+		fmt.Fprintln(console, "    git push -u origin main")
+		fmt.Fprintln(console)
+	}
+}
+
+// generateShard writes one part in its repository, resuming an earlier
+// run, and pushes it if there is a remote.
+func generateShard(opts runOptions, s shard.Shard, base, remote string, design *git.Design) shardEvent {
+	ev := shardEvent{Name: s.Name, Repo: shard.Dir(base, s.Name), Remote: shard.Remote(remote, s.Name)}
+	d := *design
+	d.Shard = s.Name
+	repo := &git.Repo{
+		Path:     ev.Repo,
+		Author:   opts.cfg.Author,
+		Email:    opts.cfg.Email,
+		Style:    opts.style,
+		Location: opts.cfg.Location(),
+		Design:   &d,
+	}
+
+	fmt.Fprintln(console)
+	fmt.Fprintf(console, "  %s%s%s %s%s%s\n", bold, s.Name, reset, dim, ev.Repo, reset)
+	cp, ok := prepareRepo(repo, s.Cells, opts.force)
+	if !ok {
+		ev.Error = "cancelled"
+		return ev
+	}
+	if cp == nil || !cp.Complete() {
+		if !importCells(repo, s.Cells) {
+			ev.Error = result.Error
+			return ev
+		}
+	}
+	ev.Commits = s.Commits()
+
+	if ev.Remote == "" {
+		return ev
+	}
+	if err := repo.AddRemote(opts.push.Remote, ev.Remote); err != nil {
+		ev.Error = "failed to add remote: " + err.Error()
+		warn(ev.Error)
+		return ev
+	}
+	if err := spin("Pushing to "+ev.Remote, func() error {
+		return repo.Push(opts.push)
+	}); err != nil {
+		ev.Error = "push failed: " + err.Error()
+		warn(err.Error())
+		return ev
+	}
+	ev.Pushed = true
+	return ev
+}
@@ -0,0 +1,203 @@
+// Package shard splits a plan across several repositories. The platform
+// adds up the contributions of every repository on a profile, so as long
+// as each day keeps its total the combined graph shows the whole design,
+// while each repository stays small enough to generate and push on its
+// own.
+package shard
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/levels"
+)
+
+// Strategy is how a plan is split.
+type Strategy string
+
+const (
+	// ByYear gives each calendar year of the plan its own repository. A
+	// rolling calendar's window spans two.
+	ByYear Strategy = "year"
+	// ByLayer gives each layer of the design, and the background fill,
+	// its own repository.
+	ByLayer Strategy = "layer"
+	// RoundRobin deals the commits out in turn, so every repository gets
+	// about the same number.
+	RoundRobin Strategy = "round-robin"
+)
+
+// Strategies lists the accepted strategies.
+var Strategies = []string{string(ByYear), string(ByLayer), string(RoundRobin)}
+
+// ParseStrategy parses a strategy name. Empty means no sharding.
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case "", ByYear, ByLayer, RoundRobin:
+		return Strategy(s), nil
+	}
+	return "", fmt.Errorf("unknown shard strategy: %s", s)
+}
+
+// Shard is the part of a plan one repository gets.
+type Shard struct {
+	// Name tells the shard apart, and is added to its directory and
+	// remote.
+	Name  string
+	Cells []draw.Cell
+}
+
+// Commits is the number of commits in the shard.
+func (s Shard) Commits() int {
+	return draw.TotalCommits(s.Cells)
+}
+
+// Split divides the cells of a plan into shards. n is the number of
+// repositories for RoundRobin and layers, bottom first, are the design's
+// for ByLayer; both are ignored otherwise. Shards that would get no
+// commits are left out.
+func Split(p levels.Plan, by Strategy, n int, layers []draw.Layer) ([]Shard, error) {
+	switch by {
+	case ByYear:
+		return byYear(append(append([]draw.Cell{}, p.Background...), p.Foreground...)), nil
+	case ByLayer:
+		return byLayer(p, layers), nil
+	case RoundRobin:
+		if n < 2 {
+			return nil, fmt.Errorf("round-robin needs at least 2 repositories, got %d", n)
+		}
+		return roundRobin(append(append([]draw.Cell{}, p.Background...), p.Foreground...), n), nil
+	}
+	return nil, fmt.Errorf("unknown shard strategy: %s", by)
+}
+
+func byYear(cells []draw.Cell) []Shard {
+	years := map[int][]draw.Cell{}
+	for _, c := range cells {
+		if c.Commits > 0 {
+			years[c.Date.Year()] = append(years[c.Date.Year()], c)
+		}
+	}
+	var shards []Shard
+	for year, cells := range years {
+		shards = append(shards, Shard{Name: strconv.Itoa(year), Cells: cells})
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Name < shards[j].Name })
+	return shards
+}
+
+// byLayer gives each cell of the design to the top layer that paints it,
+// and the background fill to a shard of its own. Cells no layer paints,
+// as after an invert, and designs without layers go to "design".
+func byLayer(p levels.Plan, layers []draw.Layer) []Shard {
+	shards := []Shard{{Name: "background", Cells: p.Background}}
+	index := map[string]int{"background": 0}
+	at := func(name string) *Shard {
+		i, ok := index[name]
+		if !ok {
+			i = len(shards)
+			index[name] = i
+			shards = append(shards, Shard{Name: name})
+		}
+		return &shards[i]
+	}
+	// bottom layer first, however the cells come
+	for _, l := range layers {
+		at(layerName(l))
+	}
+	for _, c := range p.Foreground {
+		name := "design"
+		for i := len(layers) - 1; i >= 0; i-- {
+			if layers[i].At(c.Week, c.Day) > 0 {
+				name = layerName(layers[i])
+				break
+			}
+		}
+		s := at(name)
+		s.Cells = append(s.Cells, c)
+	}
+	var out []Shard
+	for _, s := range shards {
+		if s.Commits() > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// layerName is a layer's name as it can go in a directory and a URL.
+func layerName(l draw.Layer) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, l.Name)
+	if name == "" {
+		return "design"
+	}
+	return name
+}
+
+// roundRobin deals the commits out one at a time in date order, carrying
+// on from one day to the next, so a day of few commits does not always
+// land in the first repository.
+func roundRobin(cells []draw.Cell, n int) []Shard {
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].Date.Before(cells[j].Date) })
+	shards := make([]Shard, n)
+	for i := range shards {
+		shards[i].Name = strconv.Itoa(i + 1)
+	}
+	next := 0
+	for _, c := range cells {
+		counts := make([]int, n)
+		for k := 0; k < c.Commits; k++ {
+			counts[next]++
+			next = (next + 1) % n
+		}
+		for i, count := range counts {
+			if count > 0 {
+				part := c
+				part.Commits = count
+				shards[i].Cells = append(shards[i].Cells, part)
+			}
+		}
+	}
+	var out []Shard
+	for _, s := range shards {
+		if len(s.Cells) > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Dir is where the shard called name of a repository at base goes:
+// gitdraw-repo becomes gitdraw-repo-2025.
+func Dir(base, name string) string {
+	return filepath.Clean(base) + "-" + name
+}
+
+// Remote is the URL the shard called name pushes to. A {shard} in url is
+// replaced by the name, otherwise the name is added to the repository's:
+// github.com/you/art.git becomes github.com/you/art-2025.git.
+func Remote(url, name string) string {
+	if url == "" {
+		return ""
+	}
+	if strings.Contains(url, "{shard}") {
+		return strings.ReplaceAll(url, "{shard}", name)
+	}
+	url = strings.TrimRight(url, "/")
+	if base, ok := strings.CutSuffix(url, ".git"); ok {
+		return base + "-" + name + ".git"
+	}
+	return url + "-" + name
+}
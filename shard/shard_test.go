@@ -0,0 +1,126 @@
+package shard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/levels"
+)
+
+func cell(date string, commits int) draw.Cell {
+	d, _ := time.Parse("2006-01-02", date)
+	return draw.Cell{Date: d.Add(12 * time.Hour), Commits: commits}
+}
+
+func plan() levels.Plan {
+	return levels.Plan{
+		Background: []draw.Cell{cell("2024-12-30", 1), cell("2025-01-02", 1)},
+		Foreground: []draw.Cell{cell("2024-12-31", 5), cell("2025-01-01", 4)},
+	}
+}
+
+// totals adds up the commits of every shard by day.
+func totals(shards []Shard) levels.Counts {
+	counts := levels.Counts{}
+	for _, s := range shards {
+		for _, c := range s.Cells {
+			counts.Add(c.Date, c.Commits)
+		}
+	}
+	return counts
+}
+
+func TestSplit(t *testing.T) {
+	want := totals([]Shard{{Cells: append(plan().Background, plan().Foreground...)}})
+
+	for _, tt := range []struct {
+		by      Strategy
+		n       int
+		names   []string
+		commits []int
+	}{
+		{ByYear, 0, []string{"2024", "2025"}, []int{6, 5}},
+		{ByLayer, 0, []string{"background", "design"}, []int{2, 9}},
+		{RoundRobin, 3, []string{"1", "2", "3"}, []int{4, 4, 3}},
+	} {
+		shards, err := Split(plan(), tt.by, tt.n, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.by, err)
+		}
+		if len(shards) != len(tt.names) {
+			t.Fatalf("%s: %d shards, want %d", tt.by, len(shards), len(tt.names))
+		}
+		for i, s := range shards {
+			if s.Name != tt.names[i] || s.Commits() != tt.commits[i] {
+				t.Errorf("%s: shard %d is %s with %d commits", tt.by, i, s.Name, s.Commits())
+			}
+		}
+		// the combined graph is the same
+		got := totals(shards)
+		for day, n := range want {
+			if got[day] != n {
+				t.Errorf("%s: %s has %d commits, want %d", tt.by, day, got[day], n)
+			}
+		}
+	}
+
+	if _, err := Split(plan(), RoundRobin, 1, nil); err == nil {
+		t.Error("round-robin over one repository accepted")
+	}
+	if shards, _ := Split(levels.Plan{Foreground: plan().Foreground}, ByLayer, 0, nil); len(shards) != 1 {
+		t.Errorf("empty background got a shard: %d shards", len(shards))
+	}
+}
+
+func TestByLayer(t *testing.T) {
+	var sky, text draw.Grid
+	sky.Rect(0, 0, 3, 0, 1, true)
+	text.Set(2, 0, 4)
+	layers := []draw.Layer{{Name: "Sky Line", Grid: sky}, {Name: "text", Grid: text}, {Name: "empty"}}
+
+	p := levels.Plan{Background: []draw.Cell{cell("2025-02-01", 1)}}
+	for w := 0; w < 4; w++ {
+		c := cell("2025-01-05", 2+w)
+		c.Week = w
+		p.Foreground = append(p.Foreground, c)
+	}
+	stray := cell("2025-03-01", 7)
+	stray.Week, stray.Day = 30, 3
+	p.Foreground = append(p.Foreground, stray)
+
+	shards, err := Split(p, ByLayer, 0, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the text wins the cell it shares with the sky, the stray cell no
+	// layer paints goes to design, and the empty layer gets nothing
+	want := []struct {
+		name    string
+		commits int
+	}{{"background", 1}, {"sky-line", 2 + 3 + 5}, {"text", 4}, {"design", 7}}
+	if len(shards) != len(want) {
+		t.Fatalf("%d shards, want %d", len(shards), len(want))
+	}
+	for i, s := range shards {
+		if s.Name != want[i].name || s.Commits() != want[i].commits {
+			t.Errorf("shard %d is %s with %d commits, want %s with %d", i, s.Name, s.Commits(), want[i].name, want[i].commits)
+		}
+	}
+}
+
+func TestRemote(t *testing.T) {
+	for url, want := range map[string]string{
+		"":                                   "",
+		"https://github.com/you/art.git":     "https://github.com/you/art-2025.git",
+		"https://github.com/you/art/":        "https://github.com/you/art-2025",
+		"git@github.com:you/{shard}-art.git": "git@github.com:you/2025-art.git",
+	} {
+		if got := Remote(url, "2025"); got != want {
+			t.Errorf("Remote(%q) = %q, want %q", url, got, want)
+		}
+	}
+	if got := Dir("out/art/", "design"); got != "out/art-design" {
+		t.Errorf("Dir = %q", got)
+	}
+}
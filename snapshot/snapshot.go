@@ -0,0 +1,318 @@
+// Package snapshot reads a contribution calendar saved from GitHub, so a
+// design can be previewed and planned on top of a real profile without
+// going online. It understands the calendar's HTML, the older SVG, and the
+// contributionCalendar of the GraphQL API.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+	"github.com/1etu/gitdraw/levels"
+)
+
+// Day is one day of a saved calendar.
+type Day struct {
+	Date  time.Time
+	Count int
+	Level int
+	// Counted reports whether the file had the day's contributions and
+	// not just its level, as some saved pages only keep the levels.
+	Counted bool
+}
+
+// Snapshot is a saved calendar, oldest day first.
+type Snapshot struct {
+	Days []Day
+}
+
+// Read parses the saved calendar at path.
+func Read(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Parse reads a saved calendar, telling JSON from markup by its first
+// character.
+func Parse(data []byte) (*Snapshot, error) {
+	data = bytes.TrimSpace(data)
+	var (
+		s   *Snapshot
+		err error
+	)
+	if len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+		s, err = parseJSON(data)
+	} else {
+		s, err = parseMarkup(string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Days) == 0 {
+		return nil, fmt.Errorf("no contribution calendar found")
+	}
+	sort.Slice(s.Days, func(i, j int) bool { return s.Days[i].Date.Before(s.Days[j].Date) })
+	return s, nil
+}
+
+// calendarJSON is the part of GraphQL's contributionCalendar that matters.
+type calendarJSON struct {
+	Weeks []struct {
+		ContributionDays []struct {
+			Date              string `json:"date"`
+			ContributionCount *int   `json:"contributionCount"`
+			ContributionLevel string `json:"contributionLevel"`
+		} `json:"contributionDays"`
+	} `json:"weeks"`
+}
+
+// quartileLevels are the values of GraphQL's ContributionLevel.
+var quartileLevels = map[string]int{
+	"NONE":            0,
+	"FIRST_QUARTILE":  1,
+	"SECOND_QUARTILE": 2,
+	"THIRD_QUARTILE":  3,
+	"FOURTH_QUARTILE": 4,
+}
+
+func parseJSON(data []byte) (*Snapshot, error) {
+	cals := findCalendars(data)
+	if cals == nil {
+		return nil, fmt.Errorf("no contributionCalendar in the JSON")
+	}
+
+	s := &Snapshot{}
+	seen := make(map[time.Time]bool)
+	leveled := true
+	for _, raw := range cals {
+		var cal calendarJSON
+		if err := json.Unmarshal(raw, &cal); err != nil {
+			return nil, fmt.Errorf("contributionCalendar: %v", err)
+		}
+		for _, w := range cal.Weeks {
+			for _, d := range w.ContributionDays {
+				date, err := parseDate(d.Date)
+				if err != nil {
+					return nil, err
+				}
+				// calendars of overlapping years share days
+				if seen[date] {
+					continue
+				}
+				seen[date] = true
+				day := Day{Date: date, Counted: d.ContributionCount != nil}
+				if day.Counted {
+					day.Count = *d.ContributionCount
+				}
+				level, ok := quartileLevels[d.ContributionLevel]
+				leveled = leveled && ok
+				day.Level = level
+				s.Days = append(s.Days, day)
+			}
+		}
+	}
+	if !leveled {
+		if s.Uncounted() > 0 {
+			return nil, fmt.Errorf("contributionCalendar has neither counts nor levels")
+		}
+		s.shade()
+	}
+	return s, nil
+}
+
+// findCalendars digs the contributionCalendar out of a GraphQL response,
+// or takes the JSON as the calendar itself if it has weeks. A list, such
+// as the responses of several queries, gives the calendars of each.
+func findCalendars(data []byte) []json.RawMessage {
+	var list []json.RawMessage
+	if json.Unmarshal(data, &list) == nil {
+		var cals []json.RawMessage
+		for _, v := range list {
+			cals = append(cals, findCalendars(v)...)
+		}
+		return cals
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return nil
+	}
+	if _, ok := obj["weeks"]; ok {
+		return []json.RawMessage{data}
+	}
+	if cal, ok := obj["contributionCalendar"]; ok {
+		return []json.RawMessage{cal}
+	}
+	for _, v := range obj {
+		if cals := findCalendars(v); cals != nil {
+			return cals
+		}
+	}
+	return nil
+}
+
+var (
+	dayTag  = regexp.MustCompile(`<(td|rect)\b[^>]*\bdata-date="[^"]*"[^>]*>`)
+	attr    = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+	tooltip = regexp.MustCompile(`<tool-tip\b[^>]*\bfor="([^"]+)"[^>]*>([^<]*)</tool-tip>`)
+	counted = regexp.MustCompile(`(?i)\b(no|[\d,]+) contributions?\b`)
+)
+
+// parseMarkup reads the cells of the calendar's HTML or SVG. Levels come
+// from data-level; counts from data-count in the SVG, and from the tooltip
+// or the screen reader text of each cell in the HTML.
+func parseMarkup(markup string) (*Snapshot, error) {
+	tips := make(map[string]string)
+	for _, m := range tooltip.FindAllStringSubmatch(markup, -1) {
+		tips[m[1]] = m[2]
+	}
+
+	s := &Snapshot{}
+	leveled := true
+	for _, loc := range dayTag.FindAllStringSubmatchIndex(markup, -1) {
+		tag := markup[loc[0]:loc[1]]
+		attrs := make(map[string]string)
+		for _, m := range attr.FindAllStringSubmatch(tag, -1) {
+			attrs[m[1]] = m[2]
+		}
+		date, err := parseDate(attrs["data-date"])
+		if err != nil {
+			return nil, err
+		}
+		day := Day{Date: date}
+
+		level, err := strconv.Atoi(attrs["data-level"])
+		leveled = leveled && err == nil
+		day.Level = min(max(level, 0), draw.MaxLevel)
+
+		text := tips[attrs["id"]]
+		if !strings.HasSuffix(tag, "/>") {
+			// the text inside the cell, up to its end tag
+			rest := markup[loc[1]:]
+			if end := strings.Index(rest, "</"+markup[loc[2]:loc[3]]+">"); end >= 0 {
+				text += " " + rest[:end]
+			}
+		}
+		day.Count, day.Counted = count(attrs["data-count"], text)
+		s.Days = append(s.Days, day)
+	}
+	if len(s.Days) > 0 && !leveled {
+		if s.Uncounted() > 0 {
+			return nil, fmt.Errorf("calendar cells have neither counts nor levels")
+		}
+		s.shade()
+	}
+	return s, nil
+}
+
+// count reads a day's contributions from a data-count attribute or from
+// text such as "No contributions on ..." or "1,024 contributions on ...".
+func count(attr, text string) (int, bool) {
+	if n, err := strconv.Atoi(attr); err == nil {
+		return n, true
+	}
+	m := counted.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	if strings.EqualFold(m[1], "no") {
+		return 0, true
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(m[1], ",", ""))
+	return n, err == nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return d, fmt.Errorf("bad date %q", s)
+	}
+	return d.Add(12 * time.Hour), nil
+}
+
+// shade works out the levels from the counts, as GitHub would.
+func (s *Snapshot) shade() {
+	counts := make([]int, len(s.Days))
+	for i, d := range s.Days {
+		counts[i] = d.Count
+	}
+	for i, level := range levels.Levels(levels.ByQuartile{}, counts) {
+		s.Days[i].Level = level
+	}
+}
+
+// Counts are the contributions by date, to plan a design on top of. A day
+// without a count counts as its level, the fewest contributions that
+// shade the year the same way.
+func (s *Snapshot) Counts() levels.Counts {
+	counts := levels.Counts{}
+	for _, d := range s.Days {
+		n := d.Count
+		if !d.Counted {
+			n = d.Level
+		}
+		if n > 0 {
+			counts.Add(d.Date, n)
+		}
+	}
+	return counts
+}
+
+// Uncounted is the number of days the file only had the level of.
+func (s *Snapshot) Uncounted() int {
+	n := 0
+	for _, d := range s.Days {
+		if !d.Counted {
+			n++
+		}
+	}
+	return n
+}
+
+// Total is the number of contributions in the snapshot.
+func (s *Snapshot) Total() int {
+	total := 0
+	for _, n := range s.Counts() {
+		total += n
+	}
+	return total
+}
+
+// Cells places the days on cal's graph for year, with their level and
+// contributions. Days the graph does not show are left out.
+func (s *Snapshot) Cells(cal draw.Calendar, year int) []draw.Cell {
+	from, to := cal.Window(year)
+	counts := s.Counts()
+	var cells []draw.Cell
+	for _, d := range s.Days {
+		if d.Date.Before(from) || d.Date.After(to) {
+			continue
+		}
+		p := draw.Locate(cal, year, d.Date)
+		cells = append(cells, draw.Cell{Week: p.Week, Day: p.Day, Level: d.Level, Date: d.Date, Commits: counts.Get(d.Date)})
+	}
+	return cells
+}
+
+// Grid is the levels of Cells, to draw under a design.
+func (s *Snapshot) Grid(cal draw.Calendar, year int) draw.Grid {
+	var g draw.Grid
+	for _, c := range s.Cells(cal, year) {
+		g.Set(c.Week, c.Day, c.Level)
+	}
+	return g
+}
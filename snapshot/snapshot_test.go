@@ -0,0 +1,164 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+const html = `<table class="ContributionCalendar-grid">
+<tbody><tr style="height: 10px">
+<td class="ContributionCalendar-label"><span class="sr-only">Sunday</span></td>
+<td tabindex="0" data-ix="0" style="width: 10px" data-date="2024-01-07" id="contribution-day-component-0-0" data-level="0" role="gridcell" class="ContributionCalendar-day"></td>
+<td tabindex="0" data-ix="1" style="width: 10px" data-date="2024-01-14" id="contribution-day-component-0-1" data-level="2" role="gridcell" class="ContributionCalendar-day"></td>
+<td tabindex="0" data-ix="2" style="width: 10px" data-date="2024-01-21" id="contribution-day-component-0-2" data-level="4" role="gridcell" class="ContributionCalendar-day"><span class="sr-only">12 contributions on January 21st.</span></td>
+</tr></tbody></table>
+<tool-tip id="tooltip-1" for="contribution-day-component-0-0" popover="manual" class="sr-only">No contributions on January 7th.</tool-tip>
+<tool-tip id="tooltip-2" for="contribution-day-component-0-1" popover="manual" class="sr-only">1,024 contributions on January 14th.</tool-tip>
+<div class="ContributionCalendar-day" data-level="3"></div>`
+
+const svg = `<svg width="828" height="128" class="js-calendar-graph-svg">
+<g transform="translate(10, 20)"><g transform="translate(0, 0)">
+<rect width="10" height="10" x="14" y="0" class="ContributionCalendar-day" data-date="2020-01-05" data-count="0" data-level="0" rx="2" ry="2"></rect>
+<rect width="10" height="10" x="14" y="13" class="ContributionCalendar-day" data-date="2020-01-06" data-count="3" data-level="1" rx="2" ry="2"/>
+<rect class="day" width="10" height="10" x="14" y="26" fill="#196127" data-count="9" data-date="2020-01-07"/>
+</g></g></svg>`
+
+const graphql = `{"data":{"user":{"contributionsCollection":{"contributionCalendar":{
+	"totalContributions": 5,
+	"weeks": [
+		{"contributionDays": [
+			{"date": "2023-12-31", "contributionCount": 0, "contributionLevel": "NONE", "weekday": 0},
+			{"date": "2024-01-01", "contributionCount": 1, "contributionLevel": "FIRST_QUARTILE", "weekday": 1}
+		]},
+		{"contributionDays": [
+			{"date": "2024-01-08", "contributionCount": 4, "contributionLevel": "FOURTH_QUARTILE", "weekday": 1}
+		]}
+	]
+}}}}}`
+
+func days(s *Snapshot) map[string]Day {
+	out := make(map[string]Day)
+	for _, d := range s.Days {
+		out[d.Date.Format("2006-01-02")] = d
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		counted bool
+		want    map[string]Day
+	}{
+		{"html", html, true, map[string]Day{
+			"2024-01-07": {Count: 0, Level: 0},
+			"2024-01-14": {Count: 1024, Level: 2},
+			"2024-01-21": {Count: 12, Level: 4},
+		}},
+		{"svg", svg, true, map[string]Day{
+			"2020-01-05": {Count: 0, Level: 0},
+			"2020-01-06": {Count: 3, Level: 1},
+			// no data-level, so the levels come from the counts
+			"2020-01-07": {Count: 9, Level: 3},
+		}},
+		{"graphql", graphql, true, map[string]Day{
+			"2023-12-31": {Count: 0, Level: 0},
+			"2024-01-01": {Count: 1, Level: 1},
+			"2024-01-08": {Count: 4, Level: 4},
+		}},
+	}
+	for _, tt := range tests {
+		s, err := Parse([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if n := s.Uncounted(); (n == 0) != tt.counted {
+			t.Errorf("%s: %d days uncounted", tt.name, n)
+		}
+		got := days(s)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d days, want %d", tt.name, len(got), len(tt.want))
+		}
+		for date, want := range tt.want {
+			if d, ok := got[date]; !ok || d.Count != want.Count || d.Level != want.Level {
+				t.Errorf("%s: %s = %+v, want %+v", tt.name, date, d, want)
+			}
+		}
+	}
+
+	for _, bad := range []string{"", "<html></html>", `{"data":{}}`, `[]`, `[{"data":{}}]`, `<td data-date="someday" data-level="1"></td>`} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestLevelsOnly(t *testing.T) {
+	s, err := Parse([]byte(`<td data-date="2024-03-03" data-level="3"></td><td data-date="2024-03-04" data-level="0"></td>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Uncounted() != 2 {
+		t.Errorf("%d days of a calendar without counts are uncounted", s.Uncounted())
+	}
+	// without counts a day counts as its level
+	if n := s.Counts().Get(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)); n != 3 {
+		t.Errorf("level 3 day counts %d", n)
+	}
+}
+
+func TestGrid(t *testing.T) {
+	s, err := Parse([]byte(graphql))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Total() != 5 {
+		t.Errorf("total = %d, want 5", s.Total())
+	}
+	cells := s.Cells(draw.GitHub{}, 2024)
+	if len(cells) != 2 {
+		t.Fatalf("%d cells in 2024, want the 2 days of 2024", len(cells))
+	}
+	g := s.Grid(draw.GitHub{}, 2024)
+	if g.At(1, 1) != 4 || g.At(0, 1) != 1 || cells[1].Commits != 4 {
+		t.Errorf("grid has %d and %d, cells %+v", g.At(0, 1), g.At(1, 1), cells)
+	}
+}
+
+func TestPartlyCounted(t *testing.T) {
+	// the second day has no tooltip, so only its level
+	s, err := Parse([]byte(`<td data-date="2024-03-03" data-level="3" id="a"></td><td data-date="2024-03-04" data-level="2" id="b"></td>
+<tool-tip for="a">7 contributions on March 3rd.</tool-tip>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Uncounted() != 1 {
+		t.Errorf("%d days uncounted, want 1", s.Uncounted())
+	}
+	counts := s.Counts()
+	if a, b := counts.Get(s.Days[0].Date), counts.Get(s.Days[1].Date); a != 7 || b != 2 {
+		t.Errorf("counts = %d, %d, want the count 7 and the level 2", a, b)
+	}
+}
+
+func TestJSONList(t *testing.T) {
+	// gh api --paginate, or a script querying a year at a time, writes the
+	// responses one after another in a list
+	other := `{"data":{"user":{"contributionsCollection":{"contributionCalendar":{"weeks": [
+		{"contributionDays": [
+			{"date": "2023-12-31", "contributionCount": 0, "contributionLevel": "NONE"},
+			{"date": "2023-06-01", "contributionCount": 2, "contributionLevel": "SECOND_QUARTILE"}
+		]}
+	]}}}}}`
+	s, err := Parse([]byte("[" + graphql + "," + other + "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Days) != 4 || s.Total() != 7 {
+		t.Errorf("%d days, %d contributions, want 4 and 7", len(s.Days), s.Total())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1etu/gitdraw/font"
+)
+
+func runSprites(args []string) {
+	setupOutput(outputText)
+
+	names := args
+	if len(names) == 0 {
+		names = font.SpriteNames()
+	}
+
+	fmt.Fprintln(console)
+	for _, name := range names {
+		s, ok := font.GetSprite(name)
+		if !ok {
+			exit(fmt.Sprintf("unknown sprite %q (try: %s)", name, strings.Join(font.SpriteNames(), ", ")))
+		}
+		fmt.Fprintf(console, "  %s:%s:%s  %s%d weeks%s\n", bold, strings.ToLower(name), reset, dim, s.Width(), reset)
+		for y := range s {
+			fmt.Fprint(console, "  ")
+			for x := 0; x < s.Width(); x++ {
+				fmt.Fprint(console, spriteCell(s.Level(x, y)))
+			}
+			fmt.Fprintln(console)
+		}
+		fmt.Fprintln(console)
+	}
+	fmt.Fprintf(console, "  %sUse a sprite in text as :name:, e.g. --text \"I :heart: GO\"%s\n\n", dim, reset)
+}
+
+func spriteCell(level int) string {
+	if !color {
+		return shades[level]
+	}
+	return palette[level] + "  " + reset
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1etu/gitdraw/git"
+)
+
+// expandText fills in the placeholders allowed in the text to draw:
+// {year} and {yy}, the year drawn on, {user} and {date}, which takes an
+// optional Go time layout such as {date:Jan} and is taken at now.
+// Unknown placeholders are left untouched.
+func expandText(text string, year int, now time.Time) string {
+	return git.ExpandFunc(text, func(key string) (string, bool) {
+		return textField(key, year, now)
+	})
+}
+
+func textField(key string, year int, now time.Time) (string, bool) {
+	name, arg, _ := strings.Cut(key, ":")
+	switch name {
+	case "year":
+		return strconv.Itoa(year), true
+	case "yy":
+		return fmt.Sprintf("%02d", year%100), true
+	case "user":
+		user, _ := git.User()
+		return user, user != ""
+	case "date":
+		if arg == "" {
+			arg = "2006-01-02"
+		}
+		return now.Format(arg), true
+	}
+	return "", false
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1etu/gitdraw/draw"
+)
+
+func TestExpandText(t *testing.T) {
+	now := time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		text string
+		year int
+		want string
+	}{
+		{"HI {year}", 2021, "HI 2021"},
+		{"'{yy}", 2005, "'05"},
+		{"{date:Jan 2}", 2021, "Mar 9"},
+		{"{date}", 2021, "2026-03-09"},
+		{"{nope} {year", 2021, "{nope} {year"},
+	} {
+		if got := expandText(tt.text, tt.year, now); got != tt.want {
+			t.Errorf("expandText(%q, %d) = %q, want %q", tt.text, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestDesignYear(t *testing.T) {
+	opts, err := parseRunFlags([]string{"--year", "2019"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := opts.design("{yy}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != draw.Text("19") {
+		t.Error("{yy} was not drawn as the target year")
+	}
+}
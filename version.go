@@ -0,0 +1,9 @@
+package main
+
+import "github.com/1etu/gitdraw/git"
+
+const version = "1.0.0"
+
+func init() {
+	git.Version = version
+}